@@ -0,0 +1,528 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/SyedDaniyalHassan/fluxcache/pkg/cluster"
+	"github.com/SyedDaniyalHassan/fluxcache/pkg/monitoring"
+)
+
+// CASResult is what CompareAndSwap and CompareAndDelete report back:
+// whether the operation applied, and a revision the caller can act on
+// either way -- the new revision on success, or the current one on
+// conflict, so a caller that lost the race knows what to retry against
+// without a second round trip.
+type CASResult struct {
+	OK       bool
+	Revision uint64
+}
+
+// CompareAndSwap stores value for key only if the key's current revision
+// (the highest Revision among every item stored for it, tombstones and
+// not-yet-reaped expired items included, 0 if it doesn't exist)
+// equals ifRevision, bumping the revision by one on success. Unlike Set,
+// a mismatch never merges in a sibling: CAS exists for linearizable use
+// cases (locks, leader election) where a stale caller must fail loudly
+// rather than end up racing its own sibling, so a successful swap always
+// collapses the key down to the single new CacheItem.
+func (c *Cache) CompareAndSwap(key string, value interface{}, ttlSeconds int64, ifRevision uint64, version cluster.VersionVector, lamport uint64) CASResult {
+	exp := int64(0)
+	if ttlSeconds > 0 {
+		exp = time.Now().Add(time.Duration(ttlSeconds) * time.Second).Unix()
+	}
+	for {
+		v, loaded := c.store.Load(key)
+		var current uint64
+		if loaded {
+			for _, it := range v.(*slot).items {
+				if it.Revision > current {
+					current = it.Revision
+				}
+			}
+		}
+		if current != ifRevision {
+			return CASResult{OK: false, Revision: current}
+		}
+		incoming := CacheItem{Value: value, Expiration: exp, Version: version, Lamport: lamport, Revision: current + 1}
+		next := &slot{items: []CacheItem{incoming}}
+		if !loaded {
+			if _, raced := c.store.LoadOrStore(key, next); raced {
+				continue // someone else created the key since we checked; retry
+			}
+		} else if !c.store.CompareAndSwap(key, v, next) {
+			continue // slot changed since we read it; retry
+		}
+		c.notifyWatchers(key, revisionEvent{Revision: incoming.Revision, Value: value})
+		return CASResult{OK: true, Revision: incoming.Revision}
+	}
+}
+
+// CompareAndDelete removes key only if its current revision equals
+// ifRevision (0 meaning "key must not exist").
+func (c *Cache) CompareAndDelete(key string, ifRevision uint64) CASResult {
+	for {
+		v, loaded := c.store.Load(key)
+		if !loaded {
+			if ifRevision != 0 {
+				return CASResult{OK: false, Revision: 0}
+			}
+			return CASResult{OK: true, Revision: 0}
+		}
+		var current uint64
+		for _, it := range v.(*slot).items {
+			if it.Revision > current {
+				current = it.Revision
+			}
+		}
+		if current != ifRevision {
+			return CASResult{OK: false, Revision: current}
+		}
+		if !c.store.CompareAndDelete(key, v) {
+			continue // slot changed since we read it; retry
+		}
+		c.notifyWatchers(key, revisionEvent{Deleted: true})
+		return CASResult{OK: true, Revision: 0}
+	}
+}
+
+// ApplyDecidedRevision stores value for key at exactly revision,
+// bypassing both Set's sibling merge and CompareAndSwap's ifRevision
+// check. It's how a replica applies a CAS the coordinator (this key's
+// leader) already decided: the leader is the single source of truth for
+// the revision number, so every replica must land on that exact number
+// rather than computing its own "one past whatever I have", which could
+// drift if a replica's local revision lags or leads the leader's.
+func (c *Cache) ApplyDecidedRevision(key string, value interface{}, ttlSeconds int64, version cluster.VersionVector, lamport uint64, revision uint64) {
+	exp := int64(0)
+	if ttlSeconds > 0 {
+		exp = time.Now().Add(time.Duration(ttlSeconds) * time.Second).Unix()
+	}
+	incoming := CacheItem{Value: value, Expiration: exp, Version: version, Lamport: lamport, Revision: revision}
+	c.store.Store(key, &slot{items: []CacheItem{incoming}})
+	c.notifyWatchers(key, revisionEvent{Revision: revision, Value: value})
+}
+
+// revisionEvent is one change a /watch subscriber is told about: either
+// a new revision with its value, or a delete.
+type revisionEvent struct {
+	Revision uint64      `json:"revision"`
+	Value    interface{} `json:"value,omitempty"`
+	Deleted  bool        `json:"deleted,omitempty"`
+}
+
+// Watch subscribes to every future revision change for key. The returned
+// channel receives a revisionEvent for each CAS decision or delete
+// applied to key on this node after the call returns; cancel must be
+// called once the caller is done watching, to unregister the channel and
+// free it. Sends are non-blocking, so a slow watcher drops events rather
+// than stalling the write path that produced them.
+func (c *Cache) Watch(key string) (<-chan revisionEvent, func()) {
+	ch := make(chan revisionEvent, 16)
+	c.watchMu.Lock()
+	if c.watchers == nil {
+		c.watchers = make(map[string][]chan revisionEvent)
+	}
+	c.watchers[key] = append(c.watchers[key], ch)
+	c.watchMu.Unlock()
+
+	cancel := func() {
+		c.watchMu.Lock()
+		defer c.watchMu.Unlock()
+		subs := c.watchers[key]
+		for i, sub := range subs {
+			if sub == ch {
+				c.watchers[key] = append(subs[:i], subs[i+1:]...)
+				break
+			}
+		}
+	}
+	return ch, cancel
+}
+
+func (c *Cache) notifyWatchers(key string, ev revisionEvent) {
+	c.watchMu.Lock()
+	subs := append([]chan revisionEvent(nil), c.watchers[key]...)
+	c.watchMu.Unlock()
+	for _, ch := range subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// casRequest is the wire shape for both a client's POST /cas and its
+// coordinator-to-replica forward. Decided distinguishes the two the same
+// way req.Version != nil does for handleSet: false is a fresh client
+// request this node must decide (if it's the key's leader) or proxy (if
+// it isn't); true means a leader has already decided Revision and every
+// replica must apply it as-is.
+type casRequest struct {
+	Key        string                `json:"key"`
+	Value      interface{}           `json:"value"`
+	TTL        int64                 `json:"ttl"`
+	IfRevision uint64                `json:"if_revision"`
+	Version    cluster.VersionVector `json:"version"`
+	Lamport    uint64                `json:"lamport"`
+	Revision   uint64                `json:"revision"`
+	Decided    bool                  `json:"decided"`
+	W          string                `json:"w"`
+}
+
+// casDeleteRequest is the wire shape for DELETE /cas. A direct client
+// call carries key/if_revision as query parameters (DELETE requests
+// conventionally have no body, matching handleDelete's own /delete
+// convention); a coordinator-to-replica forward carries a JSON body with
+// Decided set, the same distinction casRequest makes for POST /cas.
+type casDeleteRequest struct {
+	Key        string `json:"key"`
+	IfRevision uint64 `json:"if_revision"`
+	Decided    bool   `json:"decided"`
+}
+
+type casResponse struct {
+	OK       bool   `json:"ok"`
+	Revision uint64 `json:"revision"`
+}
+
+// handleCAS dispatches POST /cas (compare-and-swap a value in) and
+// DELETE /cas (compare-and-swap a value out) to their own handlers --
+// the two share a path per the request's spec, so the method itself is
+// the only thing distinguishing them.
+func handleCAS(cache *Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			handleCASSet(cache, w, r)
+		case http.MethodDelete:
+			handleCASDelete(cache, w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// casLeader returns the single node responsible for deciding every CAS
+// against key, so two racing callers can never both believe they won.
+// GetResponsibleNodes' quorum is the right tool for the Dynamo-style
+// Set/Get path, where any sibling can be merged back together later, but
+// a linearizable CAS needs one consistent place to ask "what's the
+// current revision" -- this is exactly what GetResponsibleNode's
+// deterministic hash-ring owner gives us.
+func casLeader(key string) *cluster.Node {
+	return clust.GetResponsibleNode(key)
+}
+
+func handleCASSet(cache *Cache, w http.ResponseWriter, r *http.Request) {
+	var req casRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if req.Key == "" {
+		http.Error(w, "key required", http.StatusBadRequest)
+		return
+	}
+
+	if req.Decided {
+		// The leader already decided this CAS's outcome and revision;
+		// apply it verbatim rather than re-deciding, same as handleSet
+		// short-circuits on req.Version != nil -- re-running the compare
+		// here could disagree with the leader and leave this replica's
+		// revision diverged from the rest.
+		cache.ApplyDecidedRevision(req.Key, req.Value, req.TTL, req.Version, req.Lamport, req.Revision)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	leader := casLeader(req.Key)
+	if leader != nil && leader.ID != selfID {
+		proxyToLeader(w, r, leader, "/cas", casHTTPBody(req))
+		return
+	}
+
+	// Check the requested consistency level is reachable *before* deciding
+	// or applying anything: applying first and only then discovering
+	// quorum is unreachable would leave the leader's (and possibly some
+	// replicas') revision advanced even though the client was told the
+	// write failed, so a retry with the same if_revision would spuriously
+	// see 409 against its own failed attempt.
+	level := consistencyFromRequest(r, req.W, defaultW)
+	replicas := clust.GetResponsibleNodes(req.Key, replicaCount)
+	healthyReplicas := []*cluster.Node{}
+	for _, node := range replicas {
+		if clust.Health == nil || clust.Health.IsNodeHealthy(node.ID) {
+			healthyReplicas = append(healthyReplicas, node)
+		}
+	}
+	needed := level.Resolve(len(replicas))
+	if len(healthyReplicas) < needed {
+		monitoring.QuorumFailures.WithLabelValues("cas").Inc()
+		http.Error(w, "insufficient healthy replicas for requested consistency", http.StatusServiceUnavailable)
+		return
+	}
+
+	observed := uint64(0)
+	if items, ok := cache.Get(req.Key); ok {
+		for _, it := range items {
+			if it.Lamport > observed {
+				observed = it.Lamport
+			}
+		}
+	}
+	version := cache.nextVersion(req.Key)
+	lamport := tickLamport(observed)
+
+	result := cache.CompareAndSwap(req.Key, req.Value, req.TTL, req.IfRevision, version, lamport)
+	if !result.OK {
+		writeCASResponse(w, http.StatusConflict, result)
+		return
+	}
+
+	decided := casRequest{Key: req.Key, Value: req.Value, TTL: req.TTL, Version: version, Lamport: lamport, Revision: result.Revision, Decided: true}
+	body, _ := json.Marshal(decided)
+	ctx, cancel := context.WithTimeout(r.Context(), coordinatorTimeout)
+	defer cancel()
+
+	acked := int32(1) // this node's own decide-and-apply above already counts
+	var wg sync.WaitGroup
+	for _, node := range replicas {
+		if node.ID == selfID {
+			continue
+		}
+		node := node
+		if clust.Health != nil && !clust.Health.IsNodeHealthy(node.ID) {
+			if hints != nil {
+				hints.Enqueue(node.ID, body)
+			}
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := forwardRequestCtx(ctx, node, "/cas", "POST", body); err == nil {
+				atomic.AddInt32(&acked, 1)
+			} else if hints != nil {
+				hints.Enqueue(node.ID, body)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if int(acked) < needed {
+		monitoring.QuorumFailures.WithLabelValues("cas").Inc()
+		http.Error(w, "failed to reach write quorum", http.StatusServiceUnavailable)
+		return
+	}
+	writeCASResponse(w, http.StatusOK, result)
+}
+
+func handleCASDelete(cache *Cache, w http.ResponseWriter, r *http.Request) {
+	var req casDeleteRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Key == "" {
+		req.Key = r.URL.Query().Get("key")
+	}
+	if req.Key == "" {
+		http.Error(w, "key required", http.StatusBadRequest)
+		return
+	}
+	if !req.Decided {
+		if v := r.URL.Query().Get("if_revision"); v != "" {
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				http.Error(w, "invalid if_revision", http.StatusBadRequest)
+				return
+			}
+			req.IfRevision = n
+		}
+	}
+
+	if req.Decided {
+		// CAS deletes are revision-based, not version-vector based (see
+		// CompareAndDelete): the leader already decided this delete wins,
+		// so apply it as a raw store removal rather than Cache.Delete's
+		// tombstone, which exists for the separate Dynamo-style /delete
+		// path and would leave a version-vector tombstone CAS never reads.
+		cache.store.Delete(req.Key)
+		cache.notifyWatchers(req.Key, revisionEvent{Deleted: true})
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	leader := casLeader(req.Key)
+	if leader != nil && leader.ID != selfID {
+		body, _ := json.Marshal(req)
+		proxyToLeader(w, r, leader, "/cas", body)
+		return
+	}
+
+	level := consistencyFromRequest(r, "", defaultW)
+	replicas := clust.GetResponsibleNodes(req.Key, replicaCount)
+	healthyReplicas := []*cluster.Node{}
+	for _, node := range replicas {
+		if clust.Health == nil || clust.Health.IsNodeHealthy(node.ID) {
+			healthyReplicas = append(healthyReplicas, node)
+		}
+	}
+	needed := level.Resolve(len(replicas))
+	if len(healthyReplicas) < needed {
+		monitoring.QuorumFailures.WithLabelValues("cas").Inc()
+		http.Error(w, "insufficient healthy replicas for requested consistency", http.StatusServiceUnavailable)
+		return
+	}
+
+	result := cache.CompareAndDelete(req.Key, req.IfRevision)
+	if !result.OK {
+		writeCASResponse(w, http.StatusConflict, result)
+		return
+	}
+
+	decided := casDeleteRequest{Key: req.Key, Decided: true}
+	body, _ := json.Marshal(decided)
+	ctx, cancel := context.WithTimeout(r.Context(), coordinatorTimeout)
+	defer cancel()
+
+	acked := int32(1)
+	var wg sync.WaitGroup
+	for _, node := range replicas {
+		if node.ID == selfID {
+			continue
+		}
+		node := node
+		if clust.Health != nil && !clust.Health.IsNodeHealthy(node.ID) {
+			if hints != nil {
+				hints.Enqueue(node.ID, body)
+			}
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := forwardRequestCtx(ctx, node, "/cas", "DELETE", body); err == nil {
+				atomic.AddInt32(&acked, 1)
+			} else if hints != nil {
+				hints.Enqueue(node.ID, body)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if int(acked) < needed {
+		monitoring.QuorumFailures.WithLabelValues("cas").Inc()
+		http.Error(w, "failed to reach write quorum", http.StatusServiceUnavailable)
+		return
+	}
+	writeCASResponse(w, http.StatusOK, result)
+}
+
+func casHTTPBody(req casRequest) []byte {
+	body, _ := json.Marshal(req)
+	return body
+}
+
+// proxyToLeader forwards a client's CAS request to key's leader and
+// relays its response (status and body) back unmodified, so the caller
+// sees exactly what the leader decided regardless of which node in the
+// cluster it happened to reach.
+func proxyToLeader(w http.ResponseWriter, r *http.Request, leader *cluster.Node, path string, body []byte) {
+	resp, err := forwardRequest(leader, path, r.Method, body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("leader %s unreachable: %v", leader.ID, err), http.StatusServiceUnavailable)
+		return
+	}
+	defer resp.Body.Close()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+func writeCASResponse(w http.ResponseWriter, status int, result CASResult) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(casResponse{OK: result.OK, Revision: result.Revision})
+}
+
+// handleWatch streams revisions of key newer than fromRevision to the
+// client over SSE: first whatever live revision this node currently
+// holds for key, if it is newer than fromRevision, then each later one
+// as it's applied on this node. Like the
+// rest of the CAS path, a watch only sees what its own node applies, so
+// clients watching for a key's authoritative revision stream should
+// connect to that key's leader (see casLeader).
+func handleWatch(cache *Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Query().Get("key")
+		if key == "" {
+			http.Error(w, "key required", http.StatusBadRequest)
+			return
+		}
+		fromRevision := uint64(0)
+		if v := r.URL.Query().Get("from_revision"); v != "" {
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				http.Error(w, "invalid from_revision", http.StatusBadRequest)
+				return
+			}
+			fromRevision = n
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		// Register before reading the current value, so a revision that
+		// lands in between is still caught by the live stream rather than
+		// slipping through the gap.
+		events, cancel := cache.Watch(key)
+		defer cancel()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+
+		if items, ok := cache.Get(key); ok {
+			for _, it := range items {
+				if it.Revision > fromRevision {
+					writeWatchEvent(w, flusher, revisionEvent{Revision: it.Revision, Value: it.Value})
+				}
+			}
+		}
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case ev, ok := <-events:
+				if !ok {
+					return
+				}
+				if ev.Revision <= fromRevision {
+					continue
+				}
+				writeWatchEvent(w, flusher, ev)
+			}
+		}
+	}
+}
+
+func writeWatchEvent(w http.ResponseWriter, flusher http.Flusher, ev revisionEvent) {
+	payload, _ := json.Marshal(ev)
+	fmt.Fprintf(w, "data: %s\n\n", payload)
+	flusher.Flush()
+}
@@ -2,29 +2,83 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"io"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"runtime"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/SyedDaniyalHassan/fluxcache/pkg/cluster"
 	"github.com/SyedDaniyalHassan/fluxcache/pkg/monitoring"
 )
 
-// CacheItem represents a value in the cache with optional TTL and versioning
+// coordinatorTimeout bounds how long a coordinator waits for replica
+// acknowledgements when honoring a per-request consistency level.
+const coordinatorTimeout = 2 * time.Second
+
+// CacheItem represents one version of a value in the cache. Version is
+// the vector clock it was written with; Lamport is a scalar tiebreaker
+// used by a LastWriteWins ConflictResolver. Revision is a per-key
+// monotonic counter bumped on every successful mutation, independent of
+// Version/Lamport, and is what CompareAndSwap checks callers against. A
+// key can map to more than one CacheItem at a time when concurrent
+// writes produce siblings (see mergeSiblings); CompareAndSwap always
+// collapses to a single CacheItem, since CAS callers want linearizable
+// semantics rather than sibling retention.
 type CacheItem struct {
-	Value       interface{} `json:"value"`
-	Expiration  int64       `json:"expiration"`   // Unix timestamp, 0 means no expiration
-	LastUpdated int64       `json:"last_updated"` // Unix timestamp (ms) of last update
+	Value      interface{}           `json:"value"`
+	Expiration int64                 `json:"expiration"` // Unix timestamp, 0 means no expiration
+	Version    cluster.VersionVector `json:"version"`
+	Lamport    uint64                `json:"lamport"`
+	Revision   uint64                `json:"revision"`
+	Deleted    bool                  `json:"deleted,omitempty"` // tombstone: see Cache.Delete
+}
+
+// wireItem is the JSON shape a CacheItem takes on the wire, stripped of
+// Expiration (which is a local implementation detail a replica computes
+// for itself from TTL, not something worth replicating as an absolute
+// timestamp).
+type wireItem struct {
+	Value    interface{}           `json:"value"`
+	Version  cluster.VersionVector `json:"version"`
+	Lamport  uint64                `json:"lamport"`
+	Revision uint64                `json:"revision"`
+	Deleted  bool                  `json:"deleted,omitempty"`
+}
+
+// slot is the unit Cache.store actually holds for a key: a pointer
+// wrapping the sibling slice, rather than the slice itself, so the
+// store's sync.Map.CompareAndSwap (used by Set and the CAS path) can
+// detect "nobody replaced this slot since I read it" by pointer
+// identity -- a []CacheItem isn't a comparable type, so sync.Map
+// couldn't compare it directly.
+type slot struct {
+	items []CacheItem
 }
 
 // Cache is a thread-safe in-memory cache
 type Cache struct {
-	store sync.Map
+	store sync.Map // key -> *slot
+
+	locks sync.Map // key -> *sync.Mutex, guarding CoordinateSet's decide-then-commit section
+
+	watchMu  sync.Mutex
+	watchers map[string][]chan revisionEvent
+}
+
+// keyMutex returns the lock CoordinateSet holds across deciding and
+// committing a write for key.
+func (c *Cache) keyMutex(key string) *sync.Mutex {
+	v, _ := c.locks.LoadOrStore(key, &sync.Mutex{})
+	return v.(*sync.Mutex)
 }
 
 var (
@@ -32,49 +86,373 @@ var (
 	clust        *cluster.Cluster
 	selfID       string
 	replicaCount = 2
+	defaultW     = cluster.ConsistencyQuorum
+	defaultR     = cluster.ConsistencyQuorum
+	hints        *cluster.HintStore
+
+	// conflictResolver, when set, collapses concurrent sibling writes down
+	// to one value on every Set instead of retaining them for the client
+	// to resolve on GET. Nil by default (Dynamo-style sibling retention).
+	conflictResolver cluster.ConflictResolver
+
+	// lamportCounter is this node's Lamport clock, advanced past the
+	// highest counter it has observed (locally or from a peer) on every
+	// coordinated write. It exists purely as a deterministic tiebreaker
+	// for ConflictResolvers like LastWriteWins; causality itself is
+	// decided by the version vector, not by this.
+	lamportCounter uint64
 )
 
 func NewCache() *Cache {
 	return &Cache{}
 }
 
-func (c *Cache) Set(key string, value interface{}, ttlSeconds int64, lastUpdated int64) bool {
+// mergeSiblings folds incoming into existing using version-vector
+// causality: an existing sibling that already descends from incoming
+// means the write is a stale resend (a no-op); an existing sibling that
+// incoming descends from is superseded and dropped; anything else is
+// causally concurrent and is kept alongside incoming as a sibling.
+func mergeSiblings(existing []CacheItem, incoming CacheItem) ([]CacheItem, bool) {
+	merged := make([]CacheItem, 0, len(existing)+1)
+	for _, sib := range existing {
+		if sib.Version.Descends(incoming.Version) {
+			return existing, false
+		}
+		if incoming.Version.Descends(sib.Version) {
+			continue
+		}
+		merged = append(merged, sib)
+	}
+	merged = append(merged, incoming)
+	return merged, true
+}
+
+// resolveSiblings applies conflictResolver to a set of causally-concurrent
+// items and returns the single item that survives, versioned with the
+// merge of every contributing sibling's vector so the resolution itself
+// is recorded as having observed all of them, and carrying the highest
+// Revision among them.
+func resolveSiblings(key string, items []CacheItem) CacheItem {
+	sibs := make([]cluster.Sibling, len(items))
+	merged := items[0].Version.Clone()
+	var rev uint64
+	for i, it := range items {
+		sibs[i] = cluster.Sibling{Value: it.Value, Version: it.Version, Lamport: it.Lamport}
+		if i > 0 {
+			merged = merged.Merge(it.Version)
+		}
+		if it.Revision > rev {
+			rev = it.Revision
+		}
+	}
+	winner := conflictResolver(key, sibs)
+	exp := items[0].Expiration
+	deleted := items[0].Deleted
+	for _, it := range items {
+		if it.Lamport == winner.Lamport {
+			exp = it.Expiration
+			deleted = it.Deleted
+			break
+		}
+	}
+	return CacheItem{Value: winner.Value, Expiration: exp, Version: merged, Lamport: winner.Lamport, Revision: rev, Deleted: deleted}
+}
+
+// tickLamport advances the node's Lamport clock past observed (the
+// highest counter seen among a key's existing siblings) and returns the
+// new value, per the standard Lamport-clock rule.
+func tickLamport(observed uint64) uint64 {
+	for {
+		cur := atomic.LoadUint64(&lamportCounter)
+		next := cur
+		if observed > next {
+			next = observed
+		}
+		next++
+		if atomic.CompareAndSwapUint64(&lamportCounter, cur, next) {
+			return next
+		}
+	}
+}
+
+// nextRevision returns one past the highest Revision among existing, the
+// sequence Set and CompareAndSwap both advance so every mutation of a
+// key -- regardless of which path wrote it -- shares one counter.
+func nextRevision(existing []CacheItem) uint64 {
+	var max uint64
+	for _, it := range existing {
+		if it.Revision > max {
+			max = it.Revision
+		}
+	}
+	return max + 1
+}
+
+func (c *Cache) Set(key string, value interface{}, ttlSeconds int64, version cluster.VersionVector, lamport uint64) bool {
 	exp := int64(0)
 	if ttlSeconds > 0 {
 		exp = time.Now().Add(time.Duration(ttlSeconds) * time.Second).Unix()
 	}
-	item := CacheItem{Value: value, Expiration: exp, LastUpdated: lastUpdated}
 	for {
 		v, loaded := c.store.Load(key)
-		if !loaded {
-			c.store.Store(key, item)
-			return true
+		var existing []CacheItem
+		if loaded {
+			existing = v.(*slot).items
 		}
-		existing := v.(CacheItem)
-		if lastUpdated >= existing.LastUpdated {
-			c.store.Store(key, item)
-			return true
+		incoming := CacheItem{Value: value, Expiration: exp, Version: version, Lamport: lamport, Revision: nextRevision(existing)}
+		merged, changed := mergeSiblings(existing, incoming)
+		if !changed {
+			return false
 		}
-		// If incoming is older, do not update
-		return false
+		if conflictResolver != nil && len(merged) > 1 {
+			merged = []CacheItem{resolveSiblings(key, merged)}
+		}
+		next := &slot{items: merged}
+		if !loaded {
+			if _, raced := c.store.LoadOrStore(key, next); raced {
+				continue // another writer created the key first; retry against it
+			}
+		} else if !c.store.CompareAndSwap(key, v, next) {
+			continue // slot changed since we read it; retry
+		}
+		return true
 	}
 }
 
-func (c *Cache) Get(key string) (interface{}, bool) {
+// rawSiblings returns every sibling on record for key, tombstones
+// included, after trimming any that have genuinely expired (safe to drop
+// outright: expiration is absolute, so an expired entry can never become
+// relevant again). Unlike Get, tombstones are not filtered out here --
+// nextVersion and Delete need to see a tombstone's version vector and
+// Lamport counter to stamp later writes (or later deletes) as properly
+// descending it, or a stale replay could resurrect a deleted key.
+func (c *Cache) rawSiblings(key string) ([]CacheItem, bool) {
 	v, ok := c.store.Load(key)
 	if !ok {
 		return nil, false
 	}
-	item := v.(CacheItem)
-	if item.Expiration > 0 && time.Now().Unix() > item.Expiration {
+	items := v.(*slot).items
+	now := time.Now().Unix()
+	retained := make([]CacheItem, 0, len(items))
+	for _, it := range items {
+		if it.Expiration > 0 && now > it.Expiration {
+			continue
+		}
+		retained = append(retained, it)
+	}
+	if len(retained) == 0 {
 		c.store.Delete(key)
 		return nil, false
 	}
-	return item.Value, true
+	if len(retained) != len(items) {
+		// Best-effort: if a concurrent writer already replaced this slot,
+		// its write wins and the next call re-trims from the new items.
+		c.store.CompareAndSwap(key, v, &slot{items: retained})
+	}
+	return retained, true
+}
+
+// Get returns every live (unexpired, undeleted) sibling currently stored
+// for key. Most keys have exactly one; more than one means concurrent
+// writes raced and no ConflictResolver is registered to collapse them.
+// A tombstoned key (see Cache.Delete) reads as not found, the same as one
+// that was never set.
+func (c *Cache) Get(key string) ([]CacheItem, bool) {
+	items, ok := c.rawSiblings(key)
+	if !ok {
+		return nil, false
+	}
+	live := make([]CacheItem, 0, len(items))
+	for _, it := range items {
+		if !it.Deleted {
+			live = append(live, it)
+		}
+	}
+	if len(live) == 0 {
+		return nil, false
+	}
+	return live, true
+}
+
+// nextVersion returns the version vector this node should stamp a new
+// client write with: every sibling currently on record for key --
+// including tombstones, so a write racing a delete is correctly ordered
+// against it -- merged, with this node's own counter incremented.
+// Coordinators compute this once per write and forward the same stamped
+// version to every replica, rather than letting each replica increment
+// independently and diverge.
+func (c *Cache) nextVersion(key string) cluster.VersionVector {
+	base := cluster.VersionVector{}
+	if items, ok := c.rawSiblings(key); ok {
+		for _, it := range items {
+			base = base.Merge(it.Version)
+		}
+	}
+	return base.Increment(selfID)
 }
 
-func (c *Cache) Delete(key string) {
-	c.store.Delete(key)
+// CoordinateSet decides the version and Lamport counter for a fresh
+// client-originated write to key and, if applyLocally is true, commits
+// it into this node's own store -- all under a per-key lock. Deciding
+// (nextVersion/tickLamport, both plain reads of the current state) and
+// committing must happen as one atomic step: without the lock, two
+// concurrent writes to the same key on the same coordinator can both
+// read the same prior state and get stamped with an identical version,
+// which mergeSiblings then treats as a stale resend of each other --
+// silently discarding whichever commits second. applyLocally is false
+// when this coordinator isn't itself one of the key's replicas, in
+// which case only the decision is made here and the actual commit
+// happens on the replicas it's forwarded to.
+func (c *Cache) CoordinateSet(key string, value interface{}, ttlSeconds int64, applyLocally bool) (cluster.VersionVector, uint64) {
+	mu := c.keyMutex(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	observed := c.observedLamport(key)
+	version := c.nextVersion(key)
+	lamport := tickLamport(observed)
+	if applyLocally {
+		c.Set(key, value, ttlSeconds, version, lamport)
+	}
+	return version, lamport
+}
+
+// CoordinateDelete decides the version and Lamport counter for a fresh
+// client-originated delete of key and, if applyLocally is true, commits
+// the tombstone into this node's own store -- under the same per-key lock
+// CoordinateSet uses, and for the same reason: without it, a delete
+// racing a concurrent Set on this coordinator could be stamped against a
+// prior state that's already stale by the time either commits.
+func (c *Cache) CoordinateDelete(key string, applyLocally bool) (cluster.VersionVector, uint64) {
+	mu := c.keyMutex(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	observed := c.observedLamport(key)
+	version := c.nextVersion(key)
+	lamport := tickLamport(observed)
+	if applyLocally {
+		c.Delete(key, version, lamport)
+	}
+	return version, lamport
+}
+
+// observedLamport returns the highest Lamport counter among every
+// sibling on record for key, tombstones included, for tickLamport to
+// advance past.
+func (c *Cache) observedLamport(key string) uint64 {
+	observed := uint64(0)
+	if items, ok := c.rawSiblings(key); ok {
+		for _, it := range items {
+			if it.Lamport > observed {
+				observed = it.Lamport
+			}
+		}
+	}
+	return observed
+}
+
+// Delete records a tombstone for key, stamped with version and lamport
+// the same way Set stamps a value. A bare c.store.Delete here would let
+// a write that's actually stale -- replayed later from hinted handoff, a
+// straggling replica, or read-repair -- land on an empty slot, where it
+// looks exactly like a fresh write and resurrects the value the delete
+// was meant to remove. Going through mergeSiblings instead means the
+// tombstone's version vector stays on record and such a write is
+// correctly recognized as already-superseded and dropped.
+func (c *Cache) Delete(key string, version cluster.VersionVector, lamport uint64) bool {
+	for {
+		v, loaded := c.store.Load(key)
+		var existing []CacheItem
+		if loaded {
+			existing = v.(*slot).items
+		}
+		incoming := CacheItem{Version: version, Lamport: lamport, Revision: nextRevision(existing), Deleted: true}
+		merged, changed := mergeSiblings(existing, incoming)
+		if !changed {
+			return false
+		}
+		next := &slot{items: merged}
+		if !loaded {
+			if _, raced := c.store.LoadOrStore(key, next); raced {
+				continue // another writer created the key first; retry against it
+			}
+		} else if !c.store.CompareAndSwap(key, v, next) {
+			continue // slot changed since we read it; retry
+		}
+		return true
+	}
+}
+
+// Len returns the number of keys currently held, including any expired
+// entries Get hasn't reaped yet; it's the cache_size figure /ready
+// reports.
+func (c *Cache) Len() int {
+	n := 0
+	c.store.Range(func(_, _ interface{}) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+// versionsEqual reports whether a and b record the same counter for every
+// node either has an entry for.
+func versionsEqual(a, b cluster.VersionVector) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for id, n := range a {
+		if b[id] != n {
+			return false
+		}
+	}
+	return true
+}
+
+// siblingsEqual reports whether two sibling sets are the same, ignoring
+// order.
+func siblingsEqual(a, b []CacheItem) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, x := range a {
+		found := false
+		for _, y := range b {
+			if x.Lamport == y.Lamport && versionsEqual(x.Version, y.Version) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// siblingsResponse builds the JSON body for a /get response. It always
+// carries the full sibling set under "siblings" (so a coordinator reading
+// from a replica can fold it into its own merge); when there's exactly
+// one live value it's additionally flattened to top-level "value" /
+// "version" / "lamport" fields for straightforward clients, otherwise
+// "conflict" is set so the client knows it must resolve the siblings
+// itself.
+func siblingsResponse(key string, items []CacheItem) map[string]interface{} {
+	sibs := make([]wireItem, len(items))
+	for i, it := range items {
+		sibs[i] = wireItem{Value: it.Value, Version: it.Version, Lamport: it.Lamport, Revision: it.Revision}
+	}
+	resp := map[string]interface{}{"key": key, "siblings": sibs}
+	if len(items) == 1 {
+		resp["value"] = items[0].Value
+		resp["version"] = items[0].Version
+		resp["lamport"] = items[0].Lamport
+		resp["revision"] = items[0].Revision
+	} else {
+		resp["conflict"] = true
+	}
+	return resp
 }
 
 func forwardRequest(node *cluster.Node, path string, method string, body []byte) (*http.Response, error) {
@@ -88,13 +466,53 @@ func forwardRequest(node *cluster.Node, path string, method string, body []byte)
 	return client.Do(req)
 }
 
+// forwardRequestCtx is like forwardRequest but bounded by ctx, so a
+// coordinator waiting on a write/read quorum can't be stuck on a slow peer
+// past its own deadline.
+func forwardRequestCtx(ctx context.Context, node *cluster.Node, path string, method string, body []byte) error {
+	url := "http://" + node.Addr + path
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("replica %s returned status %d", node.ID, resp.StatusCode)
+	}
+	return nil
+}
+
+// consistencyFromRequest resolves the consistency level for a request,
+// preferring an explicit field value, falling back to the "?consistency="
+// query param, and finally def.
+func consistencyFromRequest(r *http.Request, field string, def cluster.ConsistencyLevel) cluster.ConsistencyLevel {
+	if field != "" {
+		if lvl, ok := cluster.ParseConsistencyLevel(field); ok {
+			return lvl
+		}
+	}
+	if q := r.URL.Query().Get("consistency"); q != "" {
+		if lvl, ok := cluster.ParseConsistencyLevel(q); ok {
+			return lvl
+		}
+	}
+	return def
+}
+
 func handleSet(cache *Cache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req struct {
-			Key         string      `json:"key"`
-			Value       interface{} `json:"value"`
-			TTL         int64       `json:"ttl"`
-			LastUpdated int64       `json:"last_updated"`
+			Key     string                `json:"key"`
+			Value   interface{}           `json:"value"`
+			TTL     int64                 `json:"ttl"`
+			Version cluster.VersionVector `json:"version"`
+			Lamport uint64                `json:"lamport"`
+			W       string                `json:"w"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, "invalid request", http.StatusBadRequest)
@@ -104,43 +522,98 @@ func handleSet(cache *Cache) http.HandlerFunc {
 			http.Error(w, "key required", http.StatusBadRequest)
 			return
 		}
-		if req.LastUpdated == 0 {
-			req.LastUpdated = time.Now().UnixNano() / int64(time.Millisecond)
+		if req.Version != nil {
+			// Already stamped by a coordinator: this is a replicated write
+			// landing on one of the replicas it picked, not a fresh
+			// client request, so just apply it locally. Re-running the
+			// coordinator logic below (GetResponsibleNodes, re-forwarding
+			// to the same replica set) would bounce the write back and
+			// forth between replicas forever.
+			cache.Set(req.Key, req.Value, req.TTL, req.Version, req.Lamport)
+			w.WriteHeader(http.StatusNoContent)
+			return
 		}
+		// Client-originated write: this node is the coordinator. Check the
+		// requested consistency level is reachable *before* deciding or
+		// committing anything, the same way a failed CAS must never have
+		// mutated the leader (see chunk0-6) -- otherwise a client that
+		// retries after a failed quorum finds the retry's own earlier
+		// attempt already applied.
+		level := consistencyFromRequest(r, req.W, defaultW)
+
 		replicas := clust.GetResponsibleNodes(req.Key, replicaCount)
-		isReplica := false
 		healthyReplicas := []*cluster.Node{}
+		unhealthyReplicas := []*cluster.Node{}
+		selfIsReplica := false
 		for _, node := range replicas {
+			if node.ID == selfID {
+				selfIsReplica = true
+			}
 			if clust.Health == nil || clust.Health.IsNodeHealthy(node.ID) {
 				healthyReplicas = append(healthyReplicas, node)
-			}
-			if node.ID == selfID {
-				isReplica = true
+			} else {
+				unhealthyReplicas = append(unhealthyReplicas, node)
 			}
 		}
-		if len(healthyReplicas) == 0 {
-			http.Error(w, "no healthy replicas", http.StatusServiceUnavailable)
+		needed := level.Resolve(len(replicas))
+		if len(healthyReplicas) < needed {
+			monitoring.QuorumFailures.WithLabelValues("set").Inc()
+			http.Error(w, "insufficient healthy replicas for requested consistency", http.StatusServiceUnavailable)
 			return
 		}
-		if !isReplica {
-			body, _ := json.Marshal(req)
-			for _, node := range healthyReplicas {
-				if node.ID != selfID {
-					forwardRequest(node, "/set", "POST", body)
-				}
+
+		// Stamp the version and Lamport counter once, here, and every
+		// replica (including itself) applies that same stamped write
+		// rather than each incrementing independently and diverging.
+		req.Version, req.Lamport = cache.CoordinateSet(req.Key, req.Value, req.TTL, selfIsReplica)
+
+		// Req.W is only meaningful to us; strip it before forwarding so we
+		// don't make downstream replicas re-apply our consistency policy.
+		req.W = ""
+		body, _ := json.Marshal(req)
+		ctx, cancel := context.WithTimeout(r.Context(), coordinatorTimeout)
+		defer cancel()
+
+		// A replica the health tracker has marked down won't be written to
+		// synchronously; stash a hint so it catches up once it recovers
+		// instead of silently losing the write.
+		for _, node := range unhealthyReplicas {
+			if node.ID != selfID && hints != nil {
+				hints.Enqueue(node.ID, body)
 			}
-			w.WriteHeader(http.StatusNoContent)
-			log.Printf("[SET] Node: %s, Key: %s, Replicas: %v, IsReplica: %v", selfID, req.Key, nodeIDs(replicas), isReplica)
-			return
 		}
-		updated := cache.Set(req.Key, req.Value, req.TTL, req.LastUpdated)
-		if !updated {
-			w.WriteHeader(http.StatusConflict)
-			w.Write([]byte("conflict: incoming update is older than current value"))
+
+		var acked int32
+		if selfIsReplica {
+			// Already committed inside CoordinateSet, under the same lock
+			// that decided the version.
+			acked = 1
+		}
+		var wg sync.WaitGroup
+		for _, node := range healthyReplicas {
+			if node.ID == selfID {
+				continue
+			}
+			node := node
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := forwardRequestCtx(ctx, node, "/set", "POST", body); err == nil {
+					atomic.AddInt32(&acked, 1)
+				} else if hints != nil {
+					hints.Enqueue(node.ID, body)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if int(acked) < needed {
+			monitoring.QuorumFailures.WithLabelValues("set").Inc()
+			http.Error(w, "failed to reach write quorum", http.StatusServiceUnavailable)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
-		log.Printf("[SET] Node: %s, Key: %s, Replicas: %v, IsReplica: %v", selfID, req.Key, nodeIDs(replicas), isReplica)
+		log.Printf("[SET] Node: %s, Key: %s, Replicas: %v, W: %d/%d", selfID, req.Key, nodeIDs(replicas), acked, needed)
 	}
 }
 
@@ -151,6 +624,8 @@ func handleGet(cache *Cache) http.HandlerFunc {
 			http.Error(w, "key required", http.StatusBadRequest)
 			return
 		}
+		level := consistencyFromRequest(r, "", defaultR)
+
 		replicas := clust.GetResponsibleNodes(key, replicaCount)
 		healthyReplicas := []*cluster.Node{}
 		for _, node := range replicas {
@@ -158,71 +633,207 @@ func handleGet(cache *Cache) http.HandlerFunc {
 				healthyReplicas = append(healthyReplicas, node)
 			}
 		}
-		if len(healthyReplicas) == 0 {
-			http.Error(w, "no healthy replicas", http.StatusServiceUnavailable)
+		needed := level.Resolve(len(replicas))
+		if len(healthyReplicas) < needed {
+			monitoring.QuorumFailures.WithLabelValues("get").Inc()
+			http.Error(w, "insufficient healthy replicas for requested consistency", http.StatusServiceUnavailable)
 			return
 		}
-		for _, node := range healthyReplicas {
-			if node.ID == selfID {
-				value, ok := cache.Get(key)
-				if ok {
-					resp := map[string]interface{}{"key": key, "value": value}
-					if item, ok := cache.store.Load(key); ok {
-						resp["last_updated"] = item.(CacheItem).LastUpdated
+
+		ctx, cancel := context.WithTimeout(r.Context(), coordinatorTimeout)
+		defer cancel()
+
+		type readResult struct {
+			node  *cluster.Node
+			items []CacheItem
+			ok    bool
+		}
+		results := make([]readResult, len(healthyReplicas))
+		var wg sync.WaitGroup
+		for i, node := range healthyReplicas {
+			i, node := i, node
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if node.ID == selfID {
+					if items, ok := cache.Get(key); ok {
+						results[i] = readResult{node: node, items: items, ok: true}
 					}
-					w.Header().Set("Content-Type", "application/json")
-					json.NewEncoder(w).Encode(resp)
 					return
 				}
-			} else {
-				url := "http://" + node.Addr + "/get?key=" + key
-				resp, err := http.Get(url)
-				if err == nil && resp.StatusCode == http.StatusOK {
-					defer resp.Body.Close()
-					w.WriteHeader(resp.StatusCode)
-					io.Copy(w, resp.Body)
+				req, err := http.NewRequestWithContext(ctx, "GET", "http://"+node.Addr+"/get?key="+key, nil)
+				if err != nil {
+					return
+				}
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil || resp.StatusCode != http.StatusOK {
 					return
 				}
+				defer resp.Body.Close()
+				var body struct {
+					Siblings []wireItem `json:"siblings"`
+				}
+				if json.NewDecoder(resp.Body).Decode(&body) == nil {
+					items := make([]CacheItem, len(body.Siblings))
+					for j, s := range body.Siblings {
+						items[j] = CacheItem{Value: s.Value, Version: s.Version, Lamport: s.Lamport, Revision: s.Revision}
+					}
+					results[i] = readResult{node: node, items: items, ok: true}
+				}
+			}()
+		}
+		wg.Wait()
+
+		// Fold every replica's sibling set into one, the same causality
+		// rules a single Cache.Set uses, so the merged view reflects
+		// everything any queried replica has seen for this key.
+		var merged []CacheItem
+		present := 0
+		for i := range results {
+			if !results[i].ok {
+				continue
+			}
+			present++
+			for _, it := range results[i].items {
+				merged, _ = mergeSiblings(merged, it)
 			}
 		}
-		http.Error(w, "not found", http.StatusNotFound)
+		if present < needed || len(merged) == 0 {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		if conflictResolver != nil && len(merged) > 1 {
+			merged = []CacheItem{resolveSiblings(key, merged)}
+		}
+
+		for i := range results {
+			if results[i].ok && !siblingsEqual(results[i].items, merged) {
+				go func(node *cluster.Node, items []CacheItem) {
+					for _, it := range items {
+						b, _ := json.Marshal(map[string]interface{}{
+							"key": key, "value": it.Value, "version": it.Version, "lamport": it.Lamport,
+						})
+						forwardRequest(node, "/set", "POST", b)
+					}
+					monitoring.ReadRepairs.Inc()
+				}(results[i].node, merged)
+			}
+		}
+
+		resp := siblingsResponse(key, merged)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
 	}
 }
 
+// deleteRequest is the wire shape for DELETE /delete. A direct client
+// call carries only key as a query parameter, matching handleDelete's
+// original convention (DELETE requests conventionally have no body); a
+// coordinator-to-replica forward carries a JSON body with Decided set and
+// the version/lamport the coordinator already stamped the tombstone
+// with -- the same distinction req.Version != nil makes for POST /set.
+type deleteRequest struct {
+	Key     string                `json:"key"`
+	Version cluster.VersionVector `json:"version"`
+	Lamport uint64                `json:"lamport"`
+	Decided bool                  `json:"decided"`
+}
+
 func handleDelete(cache *Cache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		key := r.URL.Query().Get("key")
-		if key == "" {
+		var req deleteRequest
+		if r.ContentLength > 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "invalid request", http.StatusBadRequest)
+				return
+			}
+		}
+		if req.Key == "" {
+			req.Key = r.URL.Query().Get("key")
+		}
+		if req.Key == "" {
 			http.Error(w, "key required", http.StatusBadRequest)
 			return
 		}
-		replicas := clust.GetResponsibleNodes(key, replicaCount)
-		isReplica := false
+
+		if req.Decided {
+			// Already stamped by a coordinator: apply the tombstone as-is,
+			// the same way handleSet short-circuits on req.Version != nil --
+			// re-deciding here could disagree with the coordinator and
+			// leave this replica with a different tombstone version.
+			cache.Delete(req.Key, req.Version, req.Lamport)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		level := consistencyFromRequest(r, "", defaultW)
+
+		replicas := clust.GetResponsibleNodes(req.Key, replicaCount)
 		healthyReplicas := []*cluster.Node{}
+		unhealthyReplicas := []*cluster.Node{}
+		selfIsReplica := false
 		for _, node := range replicas {
+			if node.ID == selfID {
+				selfIsReplica = true
+			}
 			if clust.Health == nil || clust.Health.IsNodeHealthy(node.ID) {
 				healthyReplicas = append(healthyReplicas, node)
-			}
-			if node.ID == selfID {
-				isReplica = true
+			} else {
+				unhealthyReplicas = append(unhealthyReplicas, node)
 			}
 		}
-		if len(healthyReplicas) == 0 {
-			http.Error(w, "no healthy replicas", http.StatusServiceUnavailable)
+		needed := level.Resolve(len(replicas))
+		if len(healthyReplicas) < needed {
+			monitoring.QuorumFailures.WithLabelValues("delete").Inc()
+			http.Error(w, "insufficient healthy replicas for requested consistency", http.StatusServiceUnavailable)
 			return
 		}
-		if !isReplica {
-			for _, node := range healthyReplicas {
-				if node.ID != selfID {
-					url := "http://" + node.Addr + "/delete?key=" + key
-					req, _ := http.NewRequest("DELETE", url, nil)
-					http.DefaultClient.Do(req)
-				}
+
+		// Stamp the tombstone's version and Lamport counter once, here,
+		// under the same per-key lock CoordinateSet uses, so every
+		// replica applies the identical tombstone rather than each
+		// deciding independently and diverging.
+		version, lamport := cache.CoordinateDelete(req.Key, selfIsReplica)
+
+		decided := deleteRequest{Key: req.Key, Version: version, Lamport: lamport, Decided: true}
+		body, _ := json.Marshal(decided)
+		ctx, cancel := context.WithTimeout(r.Context(), coordinatorTimeout)
+		defer cancel()
+
+		for _, node := range unhealthyReplicas {
+			if node.ID != selfID && hints != nil {
+				hints.Enqueue(node.ID, body)
 			}
-			w.WriteHeader(http.StatusNoContent)
+		}
+
+		var acked int32
+		if selfIsReplica {
+			// Already committed inside CoordinateDelete, above.
+			acked = 1
+		}
+		var wg sync.WaitGroup
+		for _, node := range healthyReplicas {
+			if node.ID == selfID {
+				continue
+			}
+			node := node
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := forwardRequestCtx(ctx, node, "/delete", "DELETE", body); err == nil {
+					atomic.AddInt32(&acked, 1)
+				} else if hints != nil {
+					hints.Enqueue(node.ID, body)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if int(acked) < needed {
+			monitoring.QuorumFailures.WithLabelValues("delete").Inc()
+			http.Error(w, "failed to reach write quorum", http.StatusServiceUnavailable)
 			return
 		}
-		cache.Delete(key)
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
@@ -247,6 +858,132 @@ func handleHeartbeat(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("ALIVE"))
 }
 
+// handleReady is the app-level readiness signal cluster.ReadyCheck polls:
+// unlike /heartbeat (which just proves the HTTP server is up) this
+// reports enough about internal state that a peer can tell the node is
+// actually able to serve, not just alive.
+func handleReady(w http.ResponseWriter, r *http.Request) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	lastPauseMs := float64(0)
+	if mem.NumGC > 0 {
+		lastPauseMs = float64(mem.PauseNs[(mem.NumGC+255)%256]) / 1e6
+	}
+	resp := map[string]interface{}{
+		"cache_size":       cache.Len(),
+		"goroutines":       runtime.NumGoroutine(),
+		"last_gc_pause_ms": lastPauseMs,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleHealthDetail exposes per-node, per-check health state so
+// operators can see *why* a node is considered down instead of just the
+// single aggregate flag IsNodeHealthy reports.
+func handleHealthDetail(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(clust.Health.Detail())
+}
+
+// handleHintsStatus reports the number of hinted-handoff writes queued
+// per target node awaiting replay.
+func handleHintsStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hints.Status())
+}
+
+// handleSiblings is a debug endpoint exposing this node's raw local view
+// of a key, including every unresolved concurrent sibling, bypassing the
+// quorum read path entirely.
+func handleSiblings(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		http.Error(w, "key required", http.StatusBadRequest)
+		return
+	}
+	items, ok := cache.Get(key)
+	if !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(siblingsResponse(key, items))
+}
+
+// deliverHint replays a single queued hint to its target by re-issuing
+// the original /set request.
+func deliverHint(targetID string, body []byte) error {
+	node, ok := clust.Nodes[targetID]
+	if !ok {
+		return fmt.Errorf("unknown hint target %s", targetID)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), coordinatorTimeout)
+	defer cancel()
+	return forwardRequestCtx(ctx, node, "/set", "POST", body)
+}
+
+// rebalanceToNewNode is clust.Rebalance's implementation: it scans this
+// node's own store and pushes every key newNodeID is now one of the
+// responsible replicas for directly onto it, as plain coordinator-decided
+// /set writes, so a freshly-joined node doesn't start out serving empty
+// reads for data it already owns by the ring until some unrelated write
+// or read happens to touch that key.
+//
+// This is deliberately best-effort and locally-scoped: every existing
+// replica of a key independently notices newNodeID is now responsible and
+// pushes it there, so a key can be pushed more than once -- harmless,
+// since it's the same version-vector-stamped write each time and Set's
+// mergeSiblings treats a repeat as a no-op. Tombstoned keys are not
+// migrated; a deleted key simply isn't pushed, which leaves newNodeID
+// reading it as absent, the same outcome as if it had always been a
+// replica.
+func rebalanceToNewNode(newNodeID string) {
+	node, ok := clust.Nodes[newNodeID]
+	if !ok || newNodeID == selfID {
+		return
+	}
+	now := time.Now().Unix()
+	cache.store.Range(func(k, v interface{}) bool {
+		key, _ := k.(string)
+		sl, _ := v.(*slot)
+		if sl == nil {
+			return true
+		}
+		owns := false
+		for _, n := range clust.GetResponsibleNodes(key, replicaCount) {
+			if n.ID == newNodeID {
+				owns = true
+				break
+			}
+		}
+		if !owns {
+			return true
+		}
+		for _, it := range sl.items {
+			if it.Deleted {
+				continue
+			}
+			ttl := int64(0)
+			if it.Expiration > 0 {
+				ttl = it.Expiration - now
+				if ttl <= 0 {
+					continue // expired; nothing worth pushing
+				}
+			}
+			body, _ := json.Marshal(map[string]interface{}{
+				"key": key, "value": it.Value, "ttl": ttl, "version": it.Version, "lamport": it.Lamport,
+			})
+			if resp, err := forwardRequest(node, "/set", "POST", body); err == nil {
+				resp.Body.Close()
+			} else if hints != nil {
+				hints.Enqueue(node.ID, body)
+			}
+		}
+		return true
+	})
+}
+
 func main() {
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -256,27 +993,54 @@ func main() {
 	if selfID == "" {
 		selfID = "localhost:" + port
 	}
-	// Static node discovery from config or env
-	nodesEnv := os.Getenv("NODES") // comma-separated list
-	var nodeAddrs []string
-	if nodesEnv != "" {
-		nodeAddrs = append(nodeAddrs, splitAndTrim(nodesEnv)...)
-	} else {
-		nodeAddrs = []string{selfID}
-	}
 	cache = NewCache()
-	clust = cluster.NewCluster(selfID, nodeAddrs, 100)
-	clust.StartHealthMonitor(selfID, 2*time.Second)
+	// Membership starts as just this node; gossip (see pkg/cluster/swim.go)
+	// grows the hash ring as peers are discovered, so the cluster no
+	// longer needs a static, exhaustive NODES list.
+	clust = cluster.NewCluster(selfID, []string{selfID}, 100)
+	clust.Rebalance = rebalanceToNewNode
+
+	gossipAddr := os.Getenv("GOSSIP_ADDR")
+	if gossipAddr == "" {
+		gossipAddr = deriveGossipAddr(selfID)
+	}
+	if err := clust.StartGossip(gossipAddr, 200*time.Millisecond, 5*time.Second); err != nil {
+		log.Fatalf("failed to start gossip on %s: %v", gossipAddr, err)
+	}
+
+	// NODES now holds a handful of seed gossip addresses to bootstrap
+	// from, not the full cluster membership; one reachable seed is
+	// enough to discover everyone else.
+	var seeds []string
+	if seedsEnv := os.Getenv("NODES"); seedsEnv != "" {
+		seeds = splitAndTrim(seedsEnv)
+		for _, seed := range seeds {
+			if err := clust.Join(seed); err != nil {
+				log.Printf("[FluxCache] join %s failed: %v", seed, err)
+			}
+		}
+	}
+	healthChecks := []cluster.HealthCheck{
+		cluster.TCPDialCheck{Timeout: 500 * time.Millisecond},
+		cluster.HTTPHeartbeatCheck{Timeout: time.Second},
+		cluster.ReadyCheck{Timeout: time.Second},
+		cluster.PeerViewCheck{Cluster: clust, Timeout: time.Second},
+	}
+	clust.StartHealthMonitor(selfID, 2*time.Second, healthChecks)
 
 	monitoring.InitMetrics()
 	http.Handle("/metrics", monitoring.PrometheusHandler())
 	http.HandleFunc("/set", monitoring.InstrumentHandler("set", handleSet(cache)))
 	http.HandleFunc("/get", monitoring.InstrumentHandler("get", handleGet(cache)))
 	http.HandleFunc("/delete", monitoring.InstrumentHandler("delete", handleDelete(cache)))
+	http.HandleFunc("/cas", monitoring.InstrumentHandler("cas", handleCAS(cache)))
+	http.HandleFunc("/watch", handleWatch(cache))
 	http.HandleFunc("/nodes", handleNodes)
 	http.HandleFunc("/health", handleHealth)
 	http.HandleFunc("/heartbeat", handleHeartbeat)
-	log.Printf("[FluxCache] Cache node %s running on :%s, cluster: %v", selfID, port, nodeAddrs)
+	http.HandleFunc("/ready", handleReady)
+	http.HandleFunc("/health/detail", handleHealthDetail)
+	log.Printf("[FluxCache] Cache node %s running on :%s, gossip: %s, seeds: %v", selfID, port, gossipAddr, seeds)
 
 	replicaEnv := os.Getenv("REPLICA_COUNT")
 	if replicaEnv != "" {
@@ -284,12 +1048,68 @@ func main() {
 			replicaCount = rc
 		}
 	}
+	if v := os.Getenv("W_DEFAULT"); v != "" {
+		if lvl, ok := cluster.ParseConsistencyLevel(v); ok {
+			defaultW = lvl
+		}
+	}
+	if v := os.Getenv("R_DEFAULT"); v != "" {
+		if lvl, ok := cluster.ParseConsistencyLevel(v); ok {
+			defaultR = lvl
+		}
+	}
+	// CONFLICT_RESOLVER selects how concurrent sibling writes are
+	// collapsed; unset keeps the Dynamo-style default of returning
+	// siblings to the client on GET for it to resolve.
+	if os.Getenv("CONFLICT_RESOLVER") == "lww" {
+		conflictResolver = cluster.LastWriteWins
+	}
+
+	hintsDir := os.Getenv("HINTS_DIR")
+	if hintsDir == "" {
+		hintsDir = "hints-" + strings.NewReplacer("/", "_", ":", "_").Replace(selfID)
+	}
+	hintsMax := 1000
+	if v := os.Getenv("HINTS_MAX"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			hintsMax = n
+		}
+	}
+	hintsTTL := 24 * time.Hour
+	if v := os.Getenv("HINTS_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			hintsTTL = d
+		}
+	}
+	var err error
+	hints, err = cluster.NewHintStore(hintsDir, hintsMax, hintsTTL)
+	if err != nil {
+		log.Fatalf("failed to open hint store at %s: %v", hintsDir, err)
+	}
+	hints.StartDispatcher(clust.Health, deliverHint, 2*time.Second)
+	http.HandleFunc("/hints/status", handleHintsStatus)
+	http.HandleFunc("/siblings", handleSiblings)
 
 	if err := http.ListenAndServe(":"+port, nil); err != nil {
 		log.Fatalf("server error: %v", err)
 	}
 }
 
+// deriveGossipAddr picks a default SWIM UDP address for a node whose HTTP
+// identity is host:port: same host, port offset by 1000 so multiple
+// nodes on one machine (the common local dev setup) don't collide.
+func deriveGossipAddr(nodeID string) string {
+	host, portStr, err := net.SplitHostPort(nodeID)
+	if err != nil {
+		return nodeID
+	}
+	p, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nodeID
+	}
+	return net.JoinHostPort(host, strconv.Itoa(p+1000))
+}
+
 func splitAndTrim(s string) []string {
 	var out []string
 	for _, part := range bytes.Split([]byte(s), []byte{','}) {
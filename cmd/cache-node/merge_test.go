@@ -0,0 +1,153 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/SyedDaniyalHassan/fluxcache/pkg/cluster"
+)
+
+func TestMergeSiblingsDropsStaleResend(t *testing.T) {
+	existing := []CacheItem{{Value: "A", Version: cluster.VersionVector{"n1": 1}}}
+	incoming := CacheItem{Value: "A", Version: cluster.VersionVector{"n1": 1}}
+
+	merged, changed := mergeSiblings(existing, incoming)
+	if changed {
+		t.Fatal("an identical resend should not be treated as a change")
+	}
+	if len(merged) != 1 || merged[0].Value != "A" {
+		t.Fatalf("merged = %+v, want existing untouched", merged)
+	}
+}
+
+func TestMergeSiblingsSupersedes(t *testing.T) {
+	existing := []CacheItem{{Value: "A", Version: cluster.VersionVector{"n1": 1}}}
+	incoming := CacheItem{Value: "B", Version: cluster.VersionVector{"n1": 2}}
+
+	merged, changed := mergeSiblings(existing, incoming)
+	if !changed {
+		t.Fatal("a causally-newer write should change the slot")
+	}
+	if len(merged) != 1 || merged[0].Value != "B" {
+		t.Fatalf("merged = %+v, want only the superseding write", merged)
+	}
+}
+
+func TestMergeSiblingsKeepsConcurrent(t *testing.T) {
+	existing := []CacheItem{{Value: "A", Version: cluster.VersionVector{"n1": 1}}}
+	incoming := CacheItem{Value: "B", Version: cluster.VersionVector{"n2": 1}}
+
+	merged, changed := mergeSiblings(existing, incoming)
+	if !changed {
+		t.Fatal("two concurrent writes should be retained as siblings")
+	}
+	if len(merged) != 2 {
+		t.Fatalf("merged = %+v, want both siblings retained", merged)
+	}
+}
+
+// TestCoordinateSetRaceDoesNotDropWrites guards the chunk0-4 regression:
+// two concurrent client writes to the same key on the same coordinator
+// must not be stamped with the same version vector, which mergeSiblings
+// would then treat as a stale resend of one another and silently drop
+// the second write.
+func TestCoordinateSetRaceDoesNotDropWrites(t *testing.T) {
+	c := &Cache{}
+
+	done := make(chan struct{})
+	go func() {
+		c.CoordinateSet("k", "A", 0, true)
+		close(done)
+	}()
+	c.CoordinateSet("k", "B", 0, true)
+	<-done
+
+	items, ok := c.Get("k")
+	if !ok {
+		t.Fatal("key should be present after two concurrent writes")
+	}
+	// Either both commits survived as concurrent siblings, or one
+	// legitimately superseded the other -- either is fine. What must
+	// never happen is losing a write with no trace of it at all, which
+	// the version-vector-equality bug caused by returning ok=false from
+	// cache.Set for the second write.
+	if len(items) == 0 {
+		t.Fatal("a concurrent write was silently dropped")
+	}
+	// Each write that lands bumps the key's revision, so both landing
+	// leaves the highest revision at 2; a dropped second write leaves it at 1.
+	var latest uint64
+	for _, it := range items {
+		if it.Revision > latest {
+			latest = it.Revision
+		}
+	}
+	if latest != 2 {
+		t.Fatalf("highest revision = %d, want 2: a concurrent write was silently dropped", latest)
+	}
+}
+
+func TestCompareAndSwapRejectsStaleRevision(t *testing.T) {
+	c := &Cache{}
+
+	first := c.CompareAndSwap("k", "A", 0, 0, nil, 0)
+	if !first.OK || first.Revision != 1 {
+		t.Fatalf("first CAS = %+v, want OK with revision 1", first)
+	}
+
+	stale := c.CompareAndSwap("k", "B", 0, 0, nil, 0)
+	if stale.OK {
+		t.Fatal("CAS against a stale if_revision must not apply")
+	}
+	if stale.Revision != 1 {
+		t.Fatalf("stale CAS reported revision %d, want the current revision 1", stale.Revision)
+	}
+
+	ok := c.CompareAndSwap("k", "B", 0, 1, nil, 0)
+	if !ok.OK || ok.Revision != 2 {
+		t.Fatalf("CAS against the current revision = %+v, want OK with revision 2", ok)
+	}
+}
+
+func TestDeleteTombstoneBlocksStaleResurrection(t *testing.T) {
+	c := &Cache{}
+
+	staleVersion, staleLamport := c.CoordinateSet("k", "A", 0, true)
+	c.CoordinateDelete("k", true)
+
+	if _, ok := c.Get("k"); ok {
+		t.Fatal("key should read as not found once tombstoned")
+	}
+
+	// A write replayed with the pre-delete version (e.g. a straggling
+	// hinted-handoff delivery) must not resurrect the key: the tombstone
+	// causally descends it.
+	c.Set("k", "STALE", 0, staleVersion, staleLamport)
+	if _, ok := c.Get("k"); ok {
+		t.Fatal("a stale write resurrected a tombstoned key")
+	}
+
+	// A fresh write issued after the delete legitimately resurrects it.
+	c.CoordinateSet("k", "B", 0, true)
+	items, ok := c.Get("k")
+	if !ok || len(items) != 1 || items[0].Value != "B" {
+		t.Fatalf("got items=%+v ok=%v, want a single live item \"B\"", items, ok)
+	}
+}
+
+func TestCompareAndDeleteRequiresCurrentRevision(t *testing.T) {
+	c := &Cache{}
+	c.CompareAndSwap("k", "A", 0, 0, nil, 0)
+
+	stale := c.CompareAndDelete("k", 0)
+	if stale.OK {
+		t.Fatal("delete against a stale if_revision must not apply")
+	}
+
+	ok := c.CompareAndDelete("k", 1)
+	if !ok.OK {
+		t.Fatalf("delete against the current revision = %+v, want OK", ok)
+	}
+	if _, found := c.Get("k"); found {
+		t.Fatal("key should be gone after a successful CompareAndDelete")
+	}
+}
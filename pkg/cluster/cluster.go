@@ -3,7 +3,6 @@ package cluster
 import (
 	"crypto/sha1"
 	"fmt"
-	"net/http"
 	"sort"
 	"sync"
 	"time"
@@ -76,65 +75,23 @@ func hashKey(key string) uint32 {
 	return (uint32(bs[16])<<24 | uint32(bs[17])<<16 | uint32(bs[18])<<8 | uint32(bs[19]))
 }
 
-// NodeHealthTracker tracks health of nodes
-type NodeHealthTracker struct {
-	status map[string]bool
-	mu     sync.RWMutex
-}
-
-func NewNodeHealthTracker() *NodeHealthTracker {
-	return &NodeHealthTracker{status: make(map[string]bool)}
-}
-
-func (n *NodeHealthTracker) IsNodeHealthy(nodeID string) bool {
-	n.mu.RLock()
-	defer n.mu.RUnlock()
-	healthy, ok := n.status[nodeID]
-	return ok && healthy
-}
-
-func (n *NodeHealthTracker) MarkNodeHealth(nodeID string, healthy bool) {
-	n.mu.Lock()
-	defer n.mu.Unlock()
-	n.status[nodeID] = healthy
-}
-
-// StartHealthMonitor periodically checks /heartbeat for all nodes
-func (c *Cluster) StartHealthMonitor(selfID string, interval time.Duration) {
-	if c.Health == nil {
-		c.Health = NewNodeHealthTracker()
-	}
-	go func() {
-		for {
-			c.mu.RLock()
-			for id, node := range c.Nodes {
-				if id == selfID {
-					c.Health.MarkNodeHealth(id, true)
-					continue
-				}
-				url := "http://" + node.Addr + "/heartbeat"
-				client := &http.Client{Timeout: 0}
-				resp, err := client.Get(url)
-				if err == nil && resp.StatusCode == 200 {
-					c.Health.MarkNodeHealth(id, true)
-					resp.Body.Close()
-				} else {
-					c.Health.MarkNodeHealth(id, false)
-				}
-			}
-			c.mu.RUnlock()
-			time.Sleep(interval)
-		}
-	}()
-}
-
 // Cluster manages nodes and routing
 type Cluster struct {
 	Nodes    map[string]*Node
 	HashRing *HashRing
 	SelfID   string
 	Health   *NodeHealthTracker
+	Gossip   *SWIM
 	mu       sync.RWMutex
+
+	// Rebalance, if set, is called after a node is added to the ring so
+	// the caller can push any locally-held keys the new node is now
+	// responsible for directly onto it, instead of leaving it to pick
+	// them up lazily through read-repair or a hinted-handoff replay. It's
+	// a hook rather than something this package does itself because
+	// actually streaming key data requires the cache store and the HTTP
+	// client, both of which live in cmd/cache-node, not here.
+	Rebalance func(newNodeID string)
 }
 
 func NewCluster(selfID string, nodeAddrs []string, replicas int) *Cluster {
@@ -152,6 +109,65 @@ func NewCluster(selfID string, nodeAddrs []string, replicas int) *Cluster {
 	}
 }
 
+// StartGossip starts SWIM membership on this cluster, listening for gossip
+// traffic on gossipAddr (host:port, UDP). Once running, new members
+// discovered via Join (or learned transitively through gossip) are added
+// to the hash ring automatically, and members that go quiet are evicted,
+// so the cluster no longer needs a redeploy to change topology.
+func (c *Cluster) StartGossip(gossipAddr string, period, suspectTimeout time.Duration) error {
+	s, err := newSWIM(c, c.SelfID, gossipAddr, period, suspectTimeout)
+	if err != nil {
+		return err
+	}
+	c.Gossip = s
+	s.Start()
+	return nil
+}
+
+// Join bootstraps this node's gossip membership from a single seed peer's
+// gossip address. A fresh node only needs one reachable seed to discover
+// the rest of the cluster. StartGossip must be called first.
+func (c *Cluster) Join(seed string) error {
+	if c.Gossip == nil {
+		return fmt.Errorf("gossip is not running; call StartGossip first")
+	}
+	return c.Gossip.Join(seed)
+}
+
+// addNode registers a node (discovered via gossip) and adds it to the hash
+// ring. New keys naturally start routing to it immediately; existing keys
+// already stored elsewhere also get actively pushed onto it via Rebalance
+// (if set), rather than relying solely on read-repair and hinted handoff
+// to converge onto it lazily over time.
+func (c *Cluster) addNode(id, addr string) {
+	c.mu.Lock()
+	if _, ok := c.Nodes[id]; ok {
+		c.mu.Unlock()
+		return
+	}
+	c.Nodes[id] = &Node{ID: id, Addr: addr}
+	c.HashRing.AddNode(id)
+	rebalance := c.Rebalance
+	c.mu.Unlock()
+
+	if rebalance != nil {
+		// Run off the gossip goroutine: streaming key data is a network
+		// call per owned key and must not stall SWIM message processing.
+		go rebalance(id)
+	}
+}
+
+// removeNode evicts a node (declared DEAD by gossip) from the hash ring.
+func (c *Cluster) removeNode(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.Nodes[id]; !ok {
+		return
+	}
+	delete(c.Nodes, id)
+	c.HashRing.RemoveNode(id)
+}
+
 // GetResponsibleNode returns the node responsible for a key
 func (c *Cluster) GetResponsibleNode(key string) *Node {
 	nodeID := c.HashRing.GetNode(key)
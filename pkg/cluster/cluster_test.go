@@ -0,0 +1,33 @@
+package cluster
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestAddNodeInvokesRebalanceForNewNodeOnly(t *testing.T) {
+	c := NewCluster("self", []string{"self"}, 10)
+
+	var mu sync.Mutex
+	var got []string
+	done := make(chan struct{}, 2)
+	c.Rebalance = func(newNodeID string) {
+		mu.Lock()
+		got = append(got, newNodeID)
+		mu.Unlock()
+		done <- struct{}{}
+	}
+
+	c.addNode("n1", "n1")
+	<-done
+
+	// A second addNode for the same ID is a no-op (already registered)
+	// and must not trigger another rebalance push.
+	c.addNode("n1", "n1")
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 1 || got[0] != "n1" {
+		t.Fatalf("Rebalance calls = %v, want exactly one call for n1", got)
+	}
+}
@@ -0,0 +1,48 @@
+package cluster
+
+import "strings"
+
+// ConsistencyLevel controls how many replicas must participate in a read or
+// write before the coordinator considers the operation successful.
+type ConsistencyLevel int
+
+const (
+	// ConsistencyOne is satisfied by a single replica.
+	ConsistencyOne ConsistencyLevel = iota
+	// ConsistencyQuorum requires a strict majority of replicas.
+	ConsistencyQuorum
+	// ConsistencyAll requires every replica to participate.
+	ConsistencyAll
+)
+
+// ParseConsistencyLevel parses a consistency level from its wire
+// representation, as accepted by the "w"/"r" request fields and the
+// "?consistency=" query param. It is case-insensitive.
+func ParseConsistencyLevel(s string) (ConsistencyLevel, bool) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "1", "ONE":
+		return ConsistencyOne, true
+	case "QUORUM":
+		return ConsistencyQuorum, true
+	case "ALL":
+		return ConsistencyAll, true
+	default:
+		return ConsistencyOne, false
+	}
+}
+
+// Resolve returns how many of the n responsible replicas must ack for this
+// consistency level to be satisfied.
+func (c ConsistencyLevel) Resolve(n int) int {
+	if n == 0 {
+		return 0
+	}
+	switch c {
+	case ConsistencyAll:
+		return n
+	case ConsistencyQuorum:
+		return n/2 + 1
+	default:
+		return 1
+	}
+}
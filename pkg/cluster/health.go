@@ -0,0 +1,339 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/SyedDaniyalHassan/fluxcache/pkg/monitoring"
+)
+
+// HealthCheck is one signal NodeHealthTracker samples about a peer.
+// Built-in checks cover plain reachability (TCPDialCheck), the HTTP
+// stack (HTTPHeartbeatCheck), application-level readiness (ReadyCheck),
+// and cluster-view agreement (PeerViewCheck); operators can add their own
+// by implementing the same interface.
+type HealthCheck interface {
+	// Name identifies the check for metrics and /health/detail; it must
+	// be stable and unique within a tracker.
+	Name() string
+	// Check reports whether node is healthy by this check's signal. The
+	// check is responsible for bounding its own work against ctx (or a
+	// tighter deadline derived from it) so a single hung peer can't stall
+	// the monitor loop.
+	Check(ctx context.Context, node *Node) (bool, error)
+}
+
+// TCPDialCheck passes if a TCP connection to the node's address completes
+// within Timeout. It's the cheapest signal: it catches a dead process or
+// unreachable host even if the app itself is wedged.
+type TCPDialCheck struct {
+	Timeout time.Duration
+}
+
+func (c TCPDialCheck) Name() string { return "tcp_dial" }
+
+func (c TCPDialCheck) Check(ctx context.Context, node *Node) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+	d := net.Dialer{}
+	conn, err := d.DialContext(ctx, "tcp", node.Addr)
+	if err != nil {
+		return false, err
+	}
+	conn.Close()
+	return true, nil
+}
+
+// HTTPHeartbeatCheck passes if the node's /heartbeat endpoint answers 200
+// within Timeout. Unlike the original polling loop this always bounds
+// the request with a real deadline, so a peer that accepts the
+// connection but never responds can't hang the check forever.
+type HTTPHeartbeatCheck struct {
+	Timeout time.Duration
+}
+
+func (c HTTPHeartbeatCheck) Name() string { return "http_heartbeat" }
+
+func (c HTTPHeartbeatCheck) Check(ctx context.Context, node *Node) (bool, error) {
+	return httpGetOK(ctx, node.Addr, "/heartbeat", c.Timeout)
+}
+
+// ReadyCheck passes if the node's /ready endpoint answers 200 within
+// Timeout. /ready is an app-level signal (cache size, goroutine count,
+// GC pause) the node reports about itself, so this catches a process
+// that's up and answering heartbeats but struggling internally.
+type ReadyCheck struct {
+	Timeout time.Duration
+}
+
+func (c ReadyCheck) Name() string { return "ready" }
+
+func (c ReadyCheck) Check(ctx context.Context, node *Node) (bool, error) {
+	return httpGetOK(ctx, node.Addr, "/ready", c.Timeout)
+}
+
+func httpGetOK(ctx context.Context, addr, path string, timeout time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://"+addr+path, nil)
+	if err != nil {
+		return false, err
+	}
+	// Each probe is one-shot and repeats on its own schedule, so there's
+	// nothing to gain from keep-alive; Close avoids piling up idle
+	// connections the peer's default http.Server never times out.
+	req.Close = true
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode == http.StatusOK, nil
+}
+
+// PeerViewCheck asks a peer for its own membership list (via /nodes) and
+// fails if it doesn't know about every node this cluster does. A peer
+// that's reachable but has drifted membership is the hallmark of a
+// partial network partition (split-brain) rather than a crash, and the
+// other checks can't see it.
+type PeerViewCheck struct {
+	Cluster *Cluster
+	Timeout time.Duration
+}
+
+func (c PeerViewCheck) Name() string { return "peer_view" }
+
+func (c PeerViewCheck) Check(ctx context.Context, node *Node) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://"+node.Addr+"/nodes", nil)
+	if err != nil {
+		return false, err
+	}
+	req.Close = true
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Nodes []string `json:"nodes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return false, err
+	}
+	peerView := make(map[string]struct{}, len(body.Nodes))
+	for _, id := range body.Nodes {
+		peerView[id] = struct{}{}
+	}
+	c.Cluster.mu.RLock()
+	defer c.Cluster.mu.RUnlock()
+	for id := range c.Cluster.Nodes {
+		if _, ok := peerView[id]; !ok {
+			return false, fmt.Errorf("peer %s does not know about %s: possible split-brain", node.ID, id)
+		}
+	}
+	return true, nil
+}
+
+// checkWindow is one HealthCheck's rolling pass/fail history for a node,
+// used to decide health on an N-of-M basis so a single flaky probe can't
+// flap the node's status.
+type checkWindow struct {
+	results []bool
+	next    int
+	filled  int
+}
+
+func (w *checkWindow) record(size int, ok bool) {
+	if len(w.results) != size {
+		w.results = make([]bool, size)
+		w.next = 0
+		w.filled = 0
+	}
+	w.results[w.next] = ok
+	w.next = (w.next + 1) % size
+	if w.filled < size {
+		w.filled++
+	}
+}
+
+func (w *checkWindow) passes(required int) bool {
+	if w.filled == 0 {
+		return false
+	}
+	count := 0
+	for i := 0; i < w.filled; i++ {
+		if w.results[i] {
+			count++
+		}
+	}
+	return count >= required
+}
+
+// NodeHealthTracker tracks health of nodes. For each node it keeps a
+// rolling window of pass/fail results per HealthCheck; a node counts as
+// healthy only once every check that has reported for it clears its own
+// N-of-M threshold over that window. MarkNodeHealth sets a separate
+// overall status: a node marked down is unhealthy whatever its check
+// windows say, but marking a peer up does not bypass them -- only the
+// tracker's own node, which is never checked, is healthy on status
+// alone. The SWIM gossip mirror in StartHealthMonitor uses this.
+type NodeHealthTracker struct {
+	window   int // M: how many recent results each check keeps
+	required int // N: how many of the last `window` results must pass
+	selfID   string
+
+	mu           sync.RWMutex
+	status       map[string]bool
+	checkResults map[string]map[string]*checkWindow
+}
+
+// NewNodeHealthTracker creates a tracker for a node whose own ID is
+// selfID. selfID matters because StartHealthMonitor never runs
+// HealthChecks against itself (see its node-selection loop below), so
+// self can only ever report healthy via the status map (gossip, or the
+// no-gossip MarkNodeHealth fallback); IsNodeHealthy needs to know which
+// node that exception applies to.
+func NewNodeHealthTracker(selfID string) *NodeHealthTracker {
+	return &NodeHealthTracker{
+		window:       5,
+		required:     3,
+		selfID:       selfID,
+		status:       make(map[string]bool),
+		checkResults: make(map[string]map[string]*checkWindow),
+	}
+}
+
+// IsNodeHealthy reports the node's current overall health. If gossip (or
+// another caller of MarkNodeHealth) has marked the node down, that wins
+// outright; otherwise, if any HealthChecks have reported for the node,
+// healthy means every one of them currently clears its N-of-M threshold.
+// A peer with no check results yet (just discovered by gossip, before
+// its first check round has run) is not yet considered healthy, even if
+// gossip already reports it ALIVE -- gossip liveness alone doesn't
+// satisfy the N-of-M guarantee callers expect from this method. The one
+// exception is nodeID itself: StartHealthMonitor never runs checks
+// against self, so checkResults[selfID] is permanently empty by design,
+// and self-health is expected to come from the status map alone.
+func (n *NodeHealthTracker) IsNodeHealthy(nodeID string) bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	if healthy, ok := n.status[nodeID]; ok && !healthy {
+		return false
+	}
+	checks, ok := n.checkResults[nodeID]
+	if !ok || len(checks) == 0 {
+		if nodeID != n.selfID {
+			return false
+		}
+		healthy, ok := n.status[nodeID]
+		return ok && healthy
+	}
+	for _, w := range checks {
+		if !w.passes(n.required) {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *NodeHealthTracker) MarkNodeHealth(nodeID string, healthy bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.status[nodeID] = healthy
+}
+
+// RecordCheck folds one HealthCheck's pass/fail result for nodeID into
+// its rolling window and exports the check's own
+// fluxcache_node_health{node,check} gauge, so operators can see which
+// specific signal is failing rather than just the aggregate.
+func (n *NodeHealthTracker) RecordCheck(nodeID, checkName string, ok bool) {
+	n.mu.Lock()
+	if n.checkResults[nodeID] == nil {
+		n.checkResults[nodeID] = make(map[string]*checkWindow)
+	}
+	w, exists := n.checkResults[nodeID][checkName]
+	if !exists {
+		w = &checkWindow{}
+		n.checkResults[nodeID][checkName] = w
+	}
+	w.record(n.window, ok)
+	n.mu.Unlock()
+
+	val := 0.0
+	if ok {
+		val = 1
+	}
+	monitoring.NodeHealth.WithLabelValues(nodeID, checkName).Set(val)
+}
+
+// Detail returns, for every node with recorded check results, whether
+// each check currently clears its N-of-M threshold -- the payload for
+// the /health/detail endpoint.
+func (n *NodeHealthTracker) Detail() map[string]map[string]bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	out := make(map[string]map[string]bool, len(n.checkResults))
+	for nodeID, checks := range n.checkResults {
+		perCheck := make(map[string]bool, len(checks))
+		for name, w := range checks {
+			perCheck[name] = w.passes(n.required)
+		}
+		out[nodeID] = perCheck
+	}
+	return out
+}
+
+// StartHealthMonitor runs every check in checks against each known peer
+// on interval, folding results into Health's rolling windows. When
+// gossip membership (see swim.go) is running, its ALIVE/SUSPECT/DEAD
+// view is mirrored in alongside the checks as a cheaper, faster-reacting
+// signal -- a gossip-DEAD node is treated as unhealthy outright, while a
+// gossip-ALIVE (or gossip-less) node still needs every check to pass.
+func (c *Cluster) StartHealthMonitor(selfID string, interval time.Duration, checks []HealthCheck) {
+	if c.Health == nil {
+		c.Health = NewNodeHealthTracker(selfID)
+	}
+	go func() {
+		for {
+			if c.Gossip != nil {
+				for id, alive := range c.Gossip.Liveness() {
+					c.Health.MarkNodeHealth(id, alive)
+				}
+			} else {
+				c.Health.MarkNodeHealth(selfID, true)
+			}
+
+			c.mu.RLock()
+			nodes := make([]*Node, 0, len(c.Nodes))
+			for id, node := range c.Nodes {
+				if id == selfID {
+					continue
+				}
+				nodes = append(nodes, node)
+			}
+			c.mu.RUnlock()
+
+			var wg sync.WaitGroup
+			for _, node := range nodes {
+				for _, check := range checks {
+					node, check := node, check
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						ok, _ := check.Check(context.Background(), node)
+						c.Health.RecordCheck(node.ID, check.Name(), ok)
+					}()
+				}
+			}
+			wg.Wait()
+			time.Sleep(interval)
+		}
+	}()
+}
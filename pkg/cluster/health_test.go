@@ -0,0 +1,45 @@
+package cluster
+
+import "testing"
+
+// TestIsNodeHealthyPeerDefaultsUnhealthyBeforeFirstCheck guards against the
+// bug where a peer gossip just discovered -- status marked ALIVE before its
+// first HealthCheck round has even run -- was reported healthy on the
+// gossip mirror alone, bypassing the N-of-M check gate entirely.
+func TestIsNodeHealthyPeerDefaultsUnhealthyBeforeFirstCheck(t *testing.T) {
+	tracker := NewNodeHealthTracker("self")
+	tracker.MarkNodeHealth("peer1", true)
+
+	if tracker.IsNodeHealthy("peer1") {
+		t.Fatal("IsNodeHealthy(peer1) = true, want false before its first check round completes")
+	}
+
+	tracker.RecordCheck("peer1", "tcp_dial", true)
+	tracker.RecordCheck("peer1", "tcp_dial", true)
+	tracker.RecordCheck("peer1", "tcp_dial", true)
+	if !tracker.IsNodeHealthy("peer1") {
+		t.Fatal("IsNodeHealthy(peer1) = false, want true once its checks clear the N-of-M threshold")
+	}
+}
+
+// TestIsNodeHealthySelfStillStatusOnly guards the self-node special case:
+// StartHealthMonitor never runs HealthChecks against its own ID, so self's
+// checkResults stay empty forever, and self-health must keep coming from
+// the status map instead of being caught by the peer default-unhealthy rule.
+func TestIsNodeHealthySelfStillStatusOnly(t *testing.T) {
+	tracker := NewNodeHealthTracker("self")
+
+	if tracker.IsNodeHealthy("self") {
+		t.Fatal("IsNodeHealthy(self) = true, want false before status is ever marked")
+	}
+
+	tracker.MarkNodeHealth("self", true)
+	if !tracker.IsNodeHealthy("self") {
+		t.Fatal("IsNodeHealthy(self) = false, want true once status says so, despite no check results")
+	}
+
+	tracker.MarkNodeHealth("self", false)
+	if tracker.IsNodeHealthy("self") {
+		t.Fatal("IsNodeHealthy(self) = true, want false once status says unhealthy")
+	}
+}
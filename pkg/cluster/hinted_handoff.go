@@ -0,0 +1,264 @@
+package cluster
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SyedDaniyalHassan/fluxcache/pkg/monitoring"
+)
+
+// Hint is a durably-queued write that couldn't be delivered to a replica
+// because it was unhealthy (or unreachable) at the time, kept around for
+// replay once the target recovers.
+type Hint struct {
+	TargetID string          `json:"target_id"`
+	Body     json.RawMessage `json:"body"`
+	Queued   time.Time       `json:"queued"`
+}
+
+// HintDeliverer delivers a previously-queued write to a target node, e.g.
+// by POSTing it to the target's /set endpoint.
+type HintDeliverer func(targetID string, body []byte) error
+
+// HintStore is a bounded, on-disk, per-target queue of hints awaiting
+// replay. Each target gets its own log file under dir, rewritten in full
+// whenever that target's queue changes, so a stuck target can't block
+// hints destined for others.
+type HintStore struct {
+	dir      string
+	maxHints int
+	ttl      time.Duration
+
+	mu        sync.Mutex
+	queues    map[string][]Hint
+	replaying map[string]bool
+}
+
+// NewHintStore creates (or reopens) a hint store rooted at dir. maxHints
+// bounds the number of pending hints kept per target, oldest dropped
+// first; ttl discards hints older than that once they come up for replay.
+func NewHintStore(dir string, maxHints int, ttl time.Duration) (*HintStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &HintStore{dir: dir, maxHints: maxHints, ttl: ttl, queues: make(map[string][]Hint), replaying: make(map[string]bool)}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *HintStore) logPath(targetID string) string {
+	safe := strings.NewReplacer("/", "_", ":", "_", "\\", "_").Replace(targetID)
+	return filepath.Join(s.dir, safe+".hints")
+}
+
+func (s *HintStore) load() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".hints") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		var hints []Hint
+		for _, line := range strings.Split(string(data), "\n") {
+			if line == "" {
+				continue
+			}
+			var h Hint
+			if err := json.Unmarshal([]byte(line), &h); err == nil {
+				hints = append(hints, h)
+			}
+		}
+		if len(hints) > 0 {
+			s.queues[hints[0].TargetID] = hints
+			monitoring.HintQueueDepth.WithLabelValues(hints[0].TargetID).Set(float64(len(hints)))
+		}
+	}
+	return nil
+}
+
+// Enqueue durably records a hint for targetID, dropping the oldest
+// pending hint for that target once the queue is at capacity.
+func (s *HintStore) Enqueue(targetID string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := append(s.queues[targetID], Hint{TargetID: targetID, Body: append(json.RawMessage(nil), body...), Queued: time.Now()})
+	if s.maxHints > 0 && len(q) > s.maxHints {
+		q = q[len(q)-s.maxHints:]
+	}
+	s.queues[targetID] = q
+	return s.flushLocked(targetID)
+}
+
+// flushLocked rewrites the on-disk log for targetID to match the current
+// in-memory queue. Callers must hold s.mu.
+func (s *HintStore) flushLocked(targetID string) error {
+	monitoring.HintQueueDepth.WithLabelValues(targetID).Set(float64(len(s.queues[targetID])))
+	f, err := os.Create(s.logPath(targetID))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	for _, h := range s.queues[targetID] {
+		if err := enc.Encode(h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Pending returns the number of hints currently queued for targetID.
+func (s *HintStore) Pending(targetID string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queues[targetID])
+}
+
+// Status returns the pending hint count per target, for the /hints/status
+// debug endpoint.
+func (s *HintStore) Status() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]int, len(s.queues))
+	for id, q := range s.queues {
+		out[id] = len(q)
+	}
+	return out
+}
+
+// Targets returns the IDs of targets with at least one pending hint.
+func (s *HintStore) Targets() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]string, 0, len(s.queues))
+	for id, q := range s.queues {
+		if len(q) > 0 {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// replayOnce attempts to deliver every pending hint for targetID, in
+// order, dropping any that have outlived the store's TTL. It stops at the
+// first delivery failure so later hints aren't applied out of order.
+func (s *HintStore) replayOnce(targetID string, deliver HintDeliverer) (delivered int, err error) {
+	s.mu.Lock()
+	remaining := append([]Hint(nil), s.queues[targetID]...)
+	s.mu.Unlock()
+
+	consumed := 0
+	for len(remaining) > 0 {
+		h := remaining[0]
+		if s.ttl > 0 && time.Since(h.Queued) > s.ttl {
+			remaining = remaining[1:]
+			consumed++
+			continue
+		}
+		if err := deliver(targetID, h.Body); err != nil {
+			s.commitConsumed(targetID, consumed)
+			return delivered, err
+		}
+		delivered++
+		consumed++
+		remaining = remaining[1:]
+	}
+	s.commitConsumed(targetID, consumed)
+	return delivered, nil
+}
+
+// commitConsumed removes the n hints replayOnce just finished with (delivered
+// or TTL-expired) from the front of targetID's live queue. Splicing against
+// the live queue, rather than replacing it outright with replayOnce's
+// detached snapshot, matters because delivery happens with s.mu released: a
+// concurrent Enqueue for the same target can append while a hint is in
+// flight, and overwriting the queue wholesale would silently drop it (and,
+// via flushLocked, erase it from disk too). This is safe because Enqueue
+// only ever appends and, per startReplay's single-flight guard, at most one
+// replayOnce is ever consuming from the front of a given target's queue at a
+// time -- so the first n entries of the live queue are still exactly the n
+// entries replayOnce just consumed.
+func (s *HintStore) commitConsumed(targetID string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := s.queues[targetID]
+	if n > len(q) {
+		n = len(q)
+	}
+	s.queues[targetID] = q[n:]
+	s.flushLocked(targetID)
+}
+
+// StartDispatcher watches health and, whenever a target with pending
+// hints flips from unhealthy to healthy, replays its queue with
+// exponential backoff between retries. It runs until the process exits.
+func (s *HintStore) StartDispatcher(health *NodeHealthTracker, deliver HintDeliverer, pollInterval time.Duration) {
+	go func() {
+		lastHealthy := make(map[string]bool)
+		for {
+			for _, targetID := range s.Targets() {
+				healthy := health.IsNodeHealthy(targetID)
+				if healthy && !lastHealthy[targetID] {
+					s.startReplay(targetID, deliver)
+				}
+				lastHealthy[targetID] = healthy
+			}
+			time.Sleep(pollInterval)
+		}
+	}()
+}
+
+// startReplay launches replayWithBackoff for targetID unless one is already
+// running for it. Without this guard, a target whose health flaps between
+// dispatcher polls would stack up one goroutine per flip, all retrying the
+// same queue and piling up backoff sleeps indefinitely.
+func (s *HintStore) startReplay(targetID string, deliver HintDeliverer) {
+	s.mu.Lock()
+	if s.replaying[targetID] {
+		s.mu.Unlock()
+		return
+	}
+	s.replaying[targetID] = true
+	s.mu.Unlock()
+
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			s.replaying[targetID] = false
+			s.mu.Unlock()
+		}()
+		s.replayWithBackoff(targetID, deliver)
+	}()
+}
+
+func (s *HintStore) replayWithBackoff(targetID string, deliver HintDeliverer) {
+	backoff := 500 * time.Millisecond
+	const maxBackoff = 30 * time.Second
+	for s.Pending(targetID) > 0 {
+		delivered, err := s.replayOnce(targetID, deliver)
+		if delivered > 0 {
+			monitoring.HintsReplayed.WithLabelValues(targetID).Add(float64(delivered))
+		}
+		if err == nil {
+			return
+		}
+		monitoring.HintReplayFailures.WithLabelValues(targetID).Inc()
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
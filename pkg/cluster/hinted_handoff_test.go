@@ -0,0 +1,116 @@
+package cluster
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestReplayOnceKeepsHintsEnqueuedDuringDelivery guards the bug where
+// replayOnce's delivery loop runs with the lock released, then
+// unconditionally overwrites the live queue with its stale snapshot:
+// a hint appended by a concurrent Enqueue call while delivery is in
+// flight must survive, not be silently dropped from memory and disk.
+func TestReplayOnceKeepsHintsEnqueuedDuringDelivery(t *testing.T) {
+	s, err := NewHintStore(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatalf("NewHintStore: %v", err)
+	}
+
+	if err := s.Enqueue("n1", []byte(`"first"`)); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	inDelivery := make(chan struct{})
+	release := make(chan struct{})
+	deliver := func(targetID string, body []byte) error {
+		close(inDelivery)
+		<-release
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if _, err := s.replayOnce("n1", deliver); err != nil {
+			t.Errorf("replayOnce: %v", err)
+		}
+	}()
+
+	select {
+	case <-inDelivery:
+	case <-time.After(2 * time.Second):
+		t.Fatal("deliver was never called")
+	}
+
+	if err := s.Enqueue("n1", []byte(`"second"`)); err != nil {
+		t.Fatalf("Enqueue during delivery: %v", err)
+	}
+	close(release)
+	wg.Wait()
+
+	if got := s.Pending("n1"); got != 1 {
+		t.Fatalf("Pending(n1) = %d, want 1 (the hint enqueued during delivery must survive)", got)
+	}
+
+	// It must also still be the right hint, not an empty placeholder.
+	s.mu.Lock()
+	q := append([]Hint(nil), s.queues["n1"]...)
+	s.mu.Unlock()
+	if len(q) != 1 || string(q[0].Body) != `"second"` {
+		t.Fatalf("queue after replay = %+v, want the second hint", q)
+	}
+}
+
+// TestReplayOnceDropsOnlyConsumedOnFailure checks that a failed delivery
+// still only removes the hints actually consumed (delivered or
+// TTL-expired) -- not, via a stale snapshot, any hint concurrently
+// enqueued -- while correctly leaving the failed (and later) hints queued
+// for retry.
+func TestReplayOnceDropsOnlyConsumedOnFailure(t *testing.T) {
+	s, err := NewHintStore(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatalf("NewHintStore: %v", err)
+	}
+	if err := s.Enqueue("n1", []byte(`"a"`)); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	calls := 0
+	deliver := func(targetID string, body []byte) error {
+		calls++
+		if calls == 1 {
+			// The first hint succeeds...
+			return nil
+		}
+		// ...but the second one fails after a third is enqueued during its
+		// delivery; both must stay queued.
+		if calls == 2 {
+			if err := s.Enqueue("n1", []byte(`"c"`)); err != nil {
+				t.Errorf("Enqueue: %v", err)
+			}
+		}
+		return errUnreachable
+	}
+	if err := s.Enqueue("n1", []byte(`"b"`)); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	delivered, err := s.replayOnce("n1", deliver)
+	if err == nil {
+		t.Fatal("expected replayOnce to report the delivery failure")
+	}
+	if delivered != 1 {
+		t.Fatalf("delivered = %d, want 1", delivered)
+	}
+	if got := s.Pending("n1"); got != 2 {
+		t.Fatalf("Pending(n1) = %d, want 2 (the failed hint plus the one enqueued mid-replay)", got)
+	}
+}
+
+var errUnreachable = &deliverError{"unreachable"}
+
+type deliverError struct{ msg string }
+
+func (e *deliverError) Error() string { return e.msg }
@@ -0,0 +1,456 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// memberState is a node's SWIM membership state, as this node currently
+// sees it.
+type memberState int
+
+const (
+	stateAlive memberState = iota
+	stateSuspect
+	stateDead
+)
+
+func (s memberState) String() string {
+	switch s {
+	case stateAlive:
+		return "ALIVE"
+	case stateSuspect:
+		return "SUSPECT"
+	case stateDead:
+		return "DEAD"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// member is this node's view of one cluster member. ID is the node's
+// cluster identity (its HTTP host:port, used as the hash ring's node
+// ID); GossipAddr is the separate UDP address SWIM traffic is sent to.
+type member struct {
+	ID          string
+	GossipAddr  string
+	State       memberState
+	Incarnation uint64
+	suspectedAt time.Time
+	// seen is false only for the placeholder Join creates for a seed
+	// before any real gossip about it has arrived; it forces the first
+	// real update to be accepted outright instead of being compared
+	// against the placeholder's made-up state/incarnation.
+	seen bool
+}
+
+// gossipUpdate is a single membership fact piggybacked on every SWIM
+// packet so membership changes propagate without a separate broadcast
+// round.
+type gossipUpdate struct {
+	ID          string      `json:"id"`
+	GossipAddr  string      `json:"gossip_addr"`
+	State       memberState `json:"state"`
+	Incarnation uint64      `json:"incarnation"`
+}
+
+// swimMessage is the wire format for all SWIM UDP packets: PING/ACK for
+// the direct probe, PING-REQ/PING-REQ-ACK for indirect probing through a
+// helper. From/Target carry gossip (UDP) addresses, not cluster IDs.
+type swimMessage struct {
+	Type   string         `json:"type"`
+	From   string         `json:"from"`
+	Target string         `json:"target,omitempty"`
+	Gossip []gossipUpdate `json:"gossip,omitempty"`
+}
+
+const (
+	maxGossipPerMessage = 8
+	pingReqFanout       = 3
+)
+
+// SWIM implements a SWIM-style failure detector and gossip membership
+// protocol: every protocol period, a node pings a random peer directly;
+// if that peer doesn't ACK in time, K random peers are asked to PING-REQ
+// it on the node's behalf before it's marked SUSPECT. A SUSPECT that
+// isn't refuted (an ALIVE gossip entry with a higher incarnation) within
+// the suspicion timeout is declared DEAD and evicted from the hash ring.
+// Membership updates piggyback on every PING/ACK/PING-REQ, so no separate
+// broadcast round is needed.
+type SWIM struct {
+	cluster        *Cluster
+	selfID         string
+	addr           string
+	conn           *net.UDPConn
+	period         time.Duration
+	suspectTimeout time.Duration
+
+	mu      sync.Mutex
+	members map[string]*member // keyed by GossipAddr
+	pending map[string]chan struct{}
+	incSelf uint64
+}
+
+// newSWIM creates a SWIM subsystem bound to addr (host:port, UDP) and
+// registers the cluster's own node as the sole known-alive member until
+// Join or incoming gossip teaches it about peers.
+func newSWIM(cluster *Cluster, selfID, addr string, period, suspectTimeout time.Duration) (*SWIM, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return nil, err
+	}
+	s := &SWIM{
+		cluster:        cluster,
+		selfID:         selfID,
+		addr:           addr,
+		conn:           conn,
+		period:         period,
+		suspectTimeout: suspectTimeout,
+		members:        make(map[string]*member),
+		pending:        make(map[string]chan struct{}),
+	}
+	s.members[addr] = &member{ID: selfID, GossipAddr: addr, State: stateAlive}
+	return s, nil
+}
+
+// Start launches the inbound packet loop and the periodic protocol loop.
+// Both run until the process exits.
+func (s *SWIM) Start() {
+	go s.receiveLoop()
+	go s.protocolLoop()
+}
+
+// Join bootstraps membership from a single seed's gossip address: it
+// pings the seed directly and merges whatever gossip comes back,
+// including the seed's own cluster ID. A fresh node only needs one
+// reachable seed to eventually learn the rest of the cluster through
+// subsequent protocol periods.
+func (s *SWIM) Join(seedGossipAddr string) error {
+	if seedGossipAddr == "" || seedGossipAddr == s.addr {
+		return nil
+	}
+	s.mu.Lock()
+	if _, ok := s.members[seedGossipAddr]; !ok {
+		s.members[seedGossipAddr] = &member{ID: seedGossipAddr, GossipAddr: seedGossipAddr, State: stateAlive}
+	}
+	s.mu.Unlock()
+	return s.pingAndAwait(seedGossipAddr, 2*s.period)
+}
+
+// Liveness returns the current ALIVE/not-ALIVE view of every known
+// member, keyed by cluster node ID, for NodeHealthTracker to mirror.
+func (s *SWIM) Liveness() map[string]bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]bool, len(s.members))
+	for _, m := range s.members {
+		out[m.ID] = m.State == stateAlive
+	}
+	return out
+}
+
+func (s *SWIM) protocolLoop() {
+	ticker := time.NewTicker(s.period)
+	defer ticker.Stop()
+	for range ticker.C {
+		target := s.randomPeerAddr("")
+		if target == "" {
+			continue
+		}
+		if err := s.pingAndAwait(target, s.period); err != nil {
+			s.probeViaPeers(target)
+		}
+		s.checkSuspectTimeouts()
+	}
+}
+
+func (s *SWIM) pingAndAwait(targetAddr string, timeout time.Duration) error {
+	ch := s.registerPending(targetAddr)
+	defer s.clearPending(targetAddr)
+	s.send(targetAddr, swimMessage{Type: "PING", From: s.addr, Gossip: s.sampleGossip()})
+	select {
+	case <-ch:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("ping to %s timed out", targetAddr)
+	}
+}
+
+// probeViaPeers asks a handful of random peers to PING targetAddr on this
+// node's behalf; if none of them get an ACK back to us before the
+// protocol period elapses, targetAddr is marked SUSPECT.
+func (s *SWIM) probeViaPeers(targetAddr string) {
+	helpers := s.randomPeersExcluding(targetAddr, pingReqFanout)
+	if len(helpers) == 0 {
+		s.markSuspect(targetAddr)
+		return
+	}
+	ch := s.registerPending(targetAddr)
+	defer s.clearPending(targetAddr)
+	for _, h := range helpers {
+		s.send(h, swimMessage{Type: "PING-REQ", From: s.addr, Target: targetAddr, Gossip: s.sampleGossip()})
+	}
+	select {
+	case <-ch:
+		// One of the helpers reached it; refuted.
+	case <-time.After(s.period):
+		s.markSuspect(targetAddr)
+	}
+}
+
+func (s *SWIM) receiveLoop() {
+	buf := make([]byte, 64*1024)
+	for {
+		n, _, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			continue
+		}
+		var msg swimMessage
+		if err := json.Unmarshal(buf[:n], &msg); err != nil {
+			continue
+		}
+		s.mergeGossip(msg.Gossip)
+
+		switch msg.Type {
+		case "PING":
+			s.markAliveObserved(msg.From)
+			s.send(msg.From, swimMessage{Type: "ACK", From: s.addr, Gossip: s.sampleGossip()})
+		case "ACK":
+			s.markAliveObserved(msg.From)
+			s.notifyPending(msg.From)
+		case "PING-REQ":
+			target := msg.Target
+			from := msg.From
+			go func() {
+				if err := s.pingAndAwait(target, s.period); err == nil {
+					s.send(from, swimMessage{Type: "PING-REQ-ACK", From: s.addr, Target: target})
+				}
+			}()
+		case "PING-REQ-ACK":
+			s.markAliveObserved(msg.Target)
+			s.notifyPending(msg.Target)
+		}
+	}
+}
+
+func (s *SWIM) send(addr string, msg swimMessage) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	s.conn.WriteToUDP(data, udpAddr)
+}
+
+func (s *SWIM) registerPending(key string) chan struct{} {
+	ch := make(chan struct{}, 1)
+	s.mu.Lock()
+	s.pending[key] = ch
+	s.mu.Unlock()
+	return ch
+}
+
+func (s *SWIM) notifyPending(key string) {
+	s.mu.Lock()
+	ch, ok := s.pending[key]
+	s.mu.Unlock()
+	if ok {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *SWIM) clearPending(key string) {
+	s.mu.Lock()
+	delete(s.pending, key)
+	s.mu.Unlock()
+}
+
+// randomPeerAddr returns a random known member's gossip address, other
+// than self and excludeAddr.
+func (s *SWIM) randomPeerAddr(excludeAddr string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	candidates := make([]string, 0, len(s.members))
+	for addr, m := range s.members {
+		if addr == s.addr || addr == excludeAddr || m.State == stateDead {
+			continue
+		}
+		candidates = append(candidates, addr)
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	return candidates[rand.Intn(len(candidates))]
+}
+
+func (s *SWIM) randomPeersExcluding(excludeAddr string, n int) []string {
+	s.mu.Lock()
+	candidates := make([]string, 0, len(s.members))
+	for addr, m := range s.members {
+		if addr == s.addr || addr == excludeAddr || m.State == stateDead {
+			continue
+		}
+		candidates = append(candidates, addr)
+	}
+	s.mu.Unlock()
+	rand.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+	if n > len(candidates) {
+		n = len(candidates)
+	}
+	return candidates[:n]
+}
+
+// sampleGossip returns a bounded slice of membership updates to piggyback
+// on the next outgoing packet, always including this node's own entry so
+// peers learn we're alive (or can see us refute a suspicion).
+func (s *SWIM) sampleGossip() []gossipUpdate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	self := s.members[s.addr]
+	out := []gossipUpdate{{ID: self.ID, GossipAddr: self.GossipAddr, State: self.State, Incarnation: self.Incarnation}}
+	for addr, m := range s.members {
+		if addr == s.addr {
+			continue
+		}
+		if len(out) >= maxGossipPerMessage {
+			break
+		}
+		out = append(out, gossipUpdate{ID: m.ID, GossipAddr: m.GossipAddr, State: m.State, Incarnation: m.Incarnation})
+	}
+	return out
+}
+
+// mergeGossip applies incoming membership facts using the standard SWIM
+// precedence rules: a higher incarnation always wins; at equal
+// incarnation only a more severe state (ALIVE < SUSPECT < DEAD) wins. A
+// report of this node being SUSPECT/DEAD is refuted by bumping our own
+// incarnation and re-asserting ALIVE.
+func (s *SWIM) mergeGossip(updates []gossipUpdate) {
+	for _, u := range updates {
+		if u.GossipAddr == s.addr {
+			if u.State != stateAlive {
+				s.refuteSelf(u.Incarnation)
+			}
+			continue
+		}
+		s.applyUpdate(u)
+	}
+}
+
+func (s *SWIM) refuteSelf(suspectedIncarnation uint64) {
+	s.mu.Lock()
+	if suspectedIncarnation >= s.incSelf {
+		s.incSelf = suspectedIncarnation + 1
+	}
+	self := s.members[s.addr]
+	self.Incarnation = s.incSelf
+	self.State = stateAlive
+	s.mu.Unlock()
+}
+
+func (s *SWIM) applyUpdate(u gossipUpdate) {
+	s.mu.Lock()
+	m, existed := s.members[u.GossipAddr]
+	if !existed {
+		m = &member{ID: u.ID, GossipAddr: u.GossipAddr}
+		s.members[u.GossipAddr] = m
+	}
+	accept := !m.seen || u.Incarnation > m.Incarnation || (u.Incarnation == m.Incarnation && u.State > m.State)
+	if !accept {
+		s.mu.Unlock()
+		return
+	}
+	prevState, wasSeen := m.State, m.seen
+	m.ID = u.ID
+	m.Incarnation = u.Incarnation
+	m.State = u.State
+	m.seen = true
+	if u.State == stateSuspect && prevState != stateSuspect {
+		m.suspectedAt = time.Now()
+	}
+	id, state := m.ID, m.State
+	changed := !wasSeen || prevState != state
+	s.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	switch state {
+	case stateAlive:
+		s.cluster.addNode(id, id)
+	case stateDead:
+		s.cluster.removeNode(id)
+	}
+}
+
+func (s *SWIM) markAliveObserved(gossipAddr string) {
+	if gossipAddr == "" || gossipAddr == s.addr {
+		return
+	}
+	s.mu.Lock()
+	m, ok := s.members[gossipAddr]
+	if !ok {
+		// We've heard from this address before its gossip entry arrived;
+		// mergeGossip (which runs before this call) always ships the
+		// sender's own entry, so this is effectively unreachable, but
+		// fall back to treating the address as its own ID rather than
+		// dropping the observation.
+		m = &member{ID: gossipAddr, GossipAddr: gossipAddr}
+		s.members[gossipAddr] = m
+	}
+	wasAlive := m.seen && m.State == stateAlive
+	m.State = stateAlive
+	m.seen = true
+	id := m.ID
+	s.mu.Unlock()
+	if !wasAlive {
+		s.cluster.addNode(id, id)
+	}
+}
+
+func (s *SWIM) markSuspect(gossipAddr string) {
+	s.mu.Lock()
+	m, ok := s.members[gossipAddr]
+	if !ok || m.State != stateAlive {
+		s.mu.Unlock()
+		return
+	}
+	m.State = stateSuspect
+	m.suspectedAt = time.Now()
+	id := m.ID
+	s.mu.Unlock()
+	log.Printf("[SWIM] %s suspects %s is down", s.addr, id)
+}
+
+// checkSuspectTimeouts declares any member that's been SUSPECT for longer
+// than suspectTimeout DEAD, evicting it from the hash ring.
+func (s *SWIM) checkSuspectTimeouts() {
+	var toKill []*member
+	s.mu.Lock()
+	for _, m := range s.members {
+		if m.State == stateSuspect && time.Since(m.suspectedAt) > s.suspectTimeout {
+			m.State = stateDead
+			toKill = append(toKill, m)
+		}
+	}
+	s.mu.Unlock()
+	for _, m := range toKill {
+		log.Printf("[SWIM] %s declares %s DEAD", s.addr, m.ID)
+		s.cluster.removeNode(m.ID)
+	}
+}
@@ -0,0 +1,86 @@
+package cluster
+
+// VersionVector is a per-key vector clock: cluster node ID -> the
+// monotonically increasing counter that node had reached the last time it
+// coordinated a write to this key. Comparing two vectors tells replicas
+// whether one write causally supersedes another, or whether they were
+// concurrent, without relying on wall-clock timestamps, which drift under
+// NTP skew and can silently discard the newer write.
+type VersionVector map[string]uint64
+
+// Clone returns an independent copy of v.
+func (v VersionVector) Clone() VersionVector {
+	out := make(VersionVector, len(v))
+	for id, n := range v {
+		out[id] = n
+	}
+	return out
+}
+
+// Increment bumps nodeID's counter in place and returns v for chaining.
+func (v VersionVector) Increment(nodeID string) VersionVector {
+	v[nodeID]++
+	return v
+}
+
+// Descends reports whether v is causally at or after other: every counter
+// recorded in other is matched or exceeded in v. A vector descends from
+// itself, and from the empty vector.
+func (v VersionVector) Descends(other VersionVector) bool {
+	for id, n := range other {
+		if v[id] < n {
+			return false
+		}
+	}
+	return true
+}
+
+// Concurrent reports whether neither v nor other descends from the other,
+// i.e. the two were written without either side having observed the
+// other's update.
+func (v VersionVector) Concurrent(other VersionVector) bool {
+	return !v.Descends(other) && !other.Descends(v)
+}
+
+// Merge returns the component-wise maximum of v and other: the version a
+// replica should adopt after reconciling sibling values, so future
+// comparisons see both contributing writes as already known.
+func (v VersionVector) Merge(other VersionVector) VersionVector {
+	out := v.Clone()
+	for id, n := range other {
+		if n > out[id] {
+			out[id] = n
+		}
+	}
+	return out
+}
+
+// Sibling is one causally-concurrent value for a key, carried alongside
+// the version vector and Lamport counter it was written with. The Lamport
+// counter is what LastWriteWins breaks ties on; unlike a client-supplied
+// wall-clock timestamp it only ever advances on writes this cluster has
+// actually observed, so it can't be skewed by a client's clock.
+type Sibling struct {
+	Value   interface{}
+	Version VersionVector
+	Lamport uint64
+}
+
+// ConflictResolver collapses a key's causally-concurrent sibling values
+// into one. It is consulted only when two versions are Concurrent;
+// registering one trades Dynamo-style sibling retention (siblings come
+// back on every GET for the client to resolve) for reads that are always
+// resolved to a single value.
+type ConflictResolver func(key string, siblings []Sibling) Sibling
+
+// LastWriteWins is the bundled ConflictResolver fallback: it keeps the
+// sibling with the highest Lamport counter.
+func LastWriteWins(_ string, siblings []Sibling) Sibling {
+	winner := siblings[0]
+	for _, s := range siblings[1:] {
+		if s.Lamport > winner.Lamport {
+			winner = s
+		}
+	}
+	return winner
+}
@@ -0,0 +1,81 @@
+package cluster
+
+import "testing"
+
+func TestVersionVectorDescends(t *testing.T) {
+	v := VersionVector{"a": 2, "b": 1}
+
+	if !v.Descends(VersionVector{"a": 2}) {
+		t.Fatal("expected v to descend a vector it matches exactly")
+	}
+	if !v.Descends(VersionVector{}) {
+		t.Fatal("every vector descends the empty vector")
+	}
+	if !v.Descends(v) {
+		t.Fatal("every vector descends itself")
+	}
+	if v.Descends(VersionVector{"a": 3}) {
+		t.Fatal("v has not observed a:3, should not descend it")
+	}
+	if v.Descends(VersionVector{"c": 1}) {
+		t.Fatal("v has never observed node c, should not descend it")
+	}
+}
+
+func TestVersionVectorConcurrent(t *testing.T) {
+	a := VersionVector{"n1": 2}
+	b := VersionVector{"n2": 1}
+
+	if !a.Concurrent(b) {
+		t.Fatal("disjoint vectors should be concurrent")
+	}
+	if a.Concurrent(a) {
+		t.Fatal("a vector is never concurrent with itself")
+	}
+
+	descendant := VersionVector{"n1": 2, "n2": 1}
+	if a.Concurrent(descendant) {
+		t.Fatal("descendant should not be concurrent with its ancestor")
+	}
+}
+
+func TestVersionVectorMerge(t *testing.T) {
+	a := VersionVector{"n1": 2, "n2": 1}
+	b := VersionVector{"n2": 3, "n3": 1}
+
+	merged := a.Merge(b)
+	want := VersionVector{"n1": 2, "n2": 3, "n3": 1}
+	if len(merged) != len(want) {
+		t.Fatalf("merged = %v, want %v", merged, want)
+	}
+	for id, n := range want {
+		if merged[id] != n {
+			t.Fatalf("merged[%s] = %d, want %d", id, merged[id], n)
+		}
+	}
+
+	// Merge must not mutate either input.
+	if a["n2"] != 1 || b["n1"] != 0 {
+		t.Fatalf("Merge mutated an input: a=%v b=%v", a, b)
+	}
+}
+
+func TestVersionVectorIncrement(t *testing.T) {
+	v := VersionVector{}
+	v.Increment("n1")
+	v.Increment("n1")
+	if v["n1"] != 2 {
+		t.Fatalf("v[n1] = %d, want 2", v["n1"])
+	}
+}
+
+func TestLastWriteWins(t *testing.T) {
+	winner := LastWriteWins("key", []Sibling{
+		{Value: "old", Lamport: 1},
+		{Value: "new", Lamport: 5},
+		{Value: "mid", Lamport: 3},
+	})
+	if winner.Value != "new" {
+		t.Fatalf("LastWriteWins picked %v, want the highest-Lamport sibling", winner.Value)
+	}
+}
@@ -43,14 +43,52 @@ var (
 	NodeHealth = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "fluxcache_node_health",
-			Help: "Health status of nodes (1=healthy, 0=unhealthy).",
+			Help: "Per-check health status of nodes (1=passing, 0=failing), labeled by check name.",
 		},
-		[]string{"node"},
+		[]string{"node", "check"},
+	)
+	QuorumFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "fluxcache_quorum_failures_total",
+			Help: "Total number of requests that failed to reach the requested read/write quorum, by operation.",
+		},
+		[]string{"op"},
+	)
+	ReadRepairs = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "fluxcache_read_repairs_total",
+			Help: "Total number of read-repair writes pushed to lagging replicas.",
+		},
+	)
+	HintQueueDepth = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "fluxcache_hint_queue_depth",
+			Help: "Number of hinted-handoff writes currently queued for a target node.",
+		},
+		[]string{"target"},
+	)
+	HintsReplayed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "fluxcache_hints_replayed_total",
+			Help: "Total number of hinted-handoff writes successfully replayed to a target node.",
+		},
+		[]string{"target"},
+	)
+	HintReplayFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "fluxcache_hint_replay_failures_total",
+			Help: "Total number of failed hinted-handoff replay attempts to a target node.",
+		},
+		[]string{"target"},
 	)
 )
 
 func InitMetrics() {
-	prometheus.MustRegister(RequestCount, ErrorCount, RequestLatency, NodeHealth)
+	prometheus.MustRegister(
+		RequestCount, ErrorCount, RequestLatency, NodeHealth,
+		QuorumFailures, ReadRepairs,
+		HintQueueDepth, HintsReplayed, HintReplayFailures,
+	)
 }
 
 // InstrumentHandler wraps an http.HandlerFunc to collect metrics